@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/internal/statusbar"
+	"github.com/niclaszll/apsystems-ez1-tui/pkg/apsystems"
+)
+
+func runBar(args []string) error {
+	u, lang, err := loadLocale()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("bar", flag.ExitOnError)
+	host := fs.String("host", "", "Microinverter IP address or hostname (required)")
+	port := fs.Int("port", 8050, "Microinverter API port")
+	token := fs.String("token", os.Getenv("EZ1_TOKEN"), "Gateway access token (default: $EZ1_TOKEN)")
+	format := fs.String("format", "plain", "Output format: "+strings.Join(statusbar.Formats, ", "))
+	tmpl := fs.String("template", "", "Go template for the text, executed on the status (default: "+statusbar.DefaultTemplate(lang)+")")
+	maxAge := fs.Duration("max-age", 30*time.Second, "Reuse a cached reading for this long")
+	cachePath := fs.String("cache", "", "Cache file shared by all bars (default: in $XDG_CACHE_HOME/ez1-tui, \"off\" to disable)")
+	timeout := fs.Duration("timeout", 5*time.Second, "Timeout for reading the device")
+	fs.Parse(args)
+
+	if *host == "" {
+		fs.Usage()
+		return fmt.Errorf("-host flag is required")
+	}
+	formatter, err := statusbar.NewFormatter(*format, *tmpl, u, lang)
+	if err != nil {
+		return err
+	}
+
+	var opts []apsystems.ClientOption
+	if *token != "" {
+		opts = append(opts, apsystems.WithToken(*token))
+	}
+	client := apsystems.NewClient(*host, *port, opts...)
+	fetch := func(ctx context.Context) statusbar.Status {
+		ctx, cancel := context.WithTimeout(ctx, *timeout)
+		defer cancel()
+		return statusbar.Fetch(ctx, client)
+	}
+
+	ctx := context.Background()
+	var status statusbar.Status
+	switch *cachePath {
+	case "off":
+		status = fetch(ctx)
+	case "":
+		*cachePath = statusbar.DefaultCachePath(*host, *port)
+		fallthrough
+	default:
+		cache := statusbar.Cache{Path: *cachePath, MaxAge: *maxAge}
+		if status, err = cache.Get(ctx, fetch); err != nil {
+			// The bar still shows the reading; only sharing it failed.
+			fmt.Fprintf(os.Stderr, "cache: %v\n", err)
+		}
+	}
+
+	line, err := formatter.Format(status)
+	if err != nil {
+		return err
+	}
+	fmt.Println(line)
+	return nil
+}
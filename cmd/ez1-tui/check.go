@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/internal/check"
+)
+
+// runCheck never returns: like every monitoring plugin it exits with the
+// state as exit code, and with UNKNOWN on usage errors.
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg, err := parseCheckFlags(fs, args)
+	if err != nil {
+		fmt.Printf("EZ1 UNKNOWN - %v\n", err)
+		fs.SetOutput(os.Stdout)
+		fs.PrintDefaults()
+		os.Exit(int(check.Unknown))
+	}
+
+	result := check.Run(context.Background(), cfg)
+	if err := result.Write(os.Stdout); err != nil {
+		os.Exit(int(check.Unknown))
+	}
+	os.Exit(int(result.State))
+	return nil
+}
+
+func parseCheckFlags(fs *flag.FlagSet, args []string) (check.Config, error) {
+	host := fs.String("host", "", "Microinverter IP address or hostname (required)")
+	port := fs.Int("port", 8050, "Microinverter API port")
+	token := fs.String("token", os.Getenv("EZ1_TOKEN"), "Gateway access token (default: $EZ1_TOKEN)")
+	timeout := fs.Duration("timeout", 10*time.Second, "Timeout for all requests together")
+	warnPower := fs.Int("warning-power", 0, "Warn if the power in W is below this during daylight hours")
+	critPower := fs.Int("critical-power", 0, "Critical if the power in W is below this during daylight hours")
+	warnLatency := fs.Duration("warning-latency", 2*time.Second, "Warn if a request takes longer")
+	critLatency := fs.Duration("critical-latency", 5*time.Second, "Critical if a request takes longer")
+	minLimit := fs.Int("min-limit", 0, "Warn if the power limit in W is below this")
+	alarm := fs.String("alarm", "critical", "State if any alarm is active (ok to ignore alarms)")
+	off := fs.String("off", "warning", "State if the inverter is switched off (ok to ignore)")
+	hours := fs.String("hours", "", "Daylight hours, e.g. 08:00-18:00 (default: sunrise to sunset with -location, else always)")
+	location := fs.String("location", os.Getenv("EZ1_LOCATION"), "Latitude,longitude of the installation for sunrise and sunset (default: $EZ1_LOCATION)")
+	if err := fs.Parse(args); err != nil {
+		return check.Config{}, err
+	}
+	if *host == "" {
+		return check.Config{}, fmt.Errorf("-host flag is required")
+	}
+
+	u, lang, err := loadLocale()
+	if err != nil {
+		return check.Config{}, err
+	}
+	cfg := check.Config{
+		Units:   u,
+		Lang:    lang,
+		Host:    *host,
+		Port:    *port,
+		Token:   *token,
+		Timeout: *timeout,
+		Thresholds: check.Thresholds{
+			WarnPower:   *warnPower,
+			CritPower:   *critPower,
+			WarnLatency: *warnLatency,
+			CritLatency: *critLatency,
+			MinLimit:    *minLimit,
+		},
+	}
+	if cfg.Alarm, err = check.ParseState(*alarm); err != nil {
+		return cfg, fmt.Errorf("-alarm: %w", err)
+	}
+	if cfg.Off, err = check.ParseState(*off); err != nil {
+		return cfg, fmt.Errorf("-off: %w", err)
+	}
+	switch {
+	case *hours != "":
+		start, end, err := parseHours(*hours)
+		if err != nil {
+			return cfg, fmt.Errorf("-hours: %w", err)
+		}
+		cfg.Window = check.Hours(start, end)
+	case *location != "":
+		lat, lon, err := parseLocation(*location)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Window = check.Sun(lat, lon)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/internal/doctor"
+)
+
+// errChecksFailed makes main exit with status 1 after the report.
+var errChecksFailed = errors.New("at least one check failed")
+
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	host := fs.String("host", "", "Microinverter IP address or hostname (required)")
+	port := fs.Int("port", 8050, "Microinverter API port")
+	token := fs.String("token", os.Getenv("EZ1_TOKEN"), "Gateway access token (default: $EZ1_TOKEN)")
+	timeout := fs.Duration("timeout", 5*time.Second, "Timeout per request")
+	asJSON := fs.Bool("json", false, "Print the report as JSON, e.g. for bug reports")
+	fs.Parse(args)
+
+	if *host == "" {
+		fs.Usage()
+		return fmt.Errorf("-host flag is required")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report := doctor.Run(ctx, doctor.Config{
+		Host:    *host,
+		Port:    *port,
+		Token:   *token,
+		Timeout: *timeout,
+		Version: version,
+	})
+	write := report.WriteText
+	if *asJSON {
+		write = report.WriteJSON
+	}
+	if err := write(os.Stdout); err != nil {
+		return err
+	}
+	if report.Failed() {
+		return errChecksFailed
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/internal/emissions"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/history"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/units"
+)
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dir := fs.String("history", history.DefaultDir(), "History directory")
+	from := fs.String("from", "", "Start of the range (YYYY-MM-DD or RFC 3339, inclusive)")
+	to := fs.String("to", "", "End of the range (YYYY-MM-DD or RFC 3339, inclusive for dates)")
+	format := fs.String("format", "csv", "Output format: csv, jsonl or tsv")
+	output := fs.String("o", "", "Output file (default: stdout)")
+	co2Intensity := fs.Float64("co2-intensity", 0, "Grid carbon intensity in g CO₂/kWh, adds a co2_today_kg column")
+	co2Profile := fs.String("co2-profile", "", "File with a time-of-day grid intensity profile")
+	locale := fs.String("locale", "", "Write decimals for spreadsheets in this locale, e.g. de (csv and tsv; default: always a point)")
+	fs.Parse(args)
+
+	f, err := history.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+	start, err := parseTime(*from, false)
+	if err != nil {
+		return fmt.Errorf("-from: %w", err)
+	}
+	end, err := parseTime(*to, true)
+	if err != nil {
+		return fmt.Errorf("-to: %w", err)
+	}
+	intensity, err := loadIntensity(*co2Intensity, *co2Profile)
+	if err != nil {
+		return err
+	}
+
+	store, err := history.Open(*dir)
+	if err != nil {
+		return err
+	}
+	samples, err := store.Range(start, end)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("create output: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	buf := bufio.NewWriter(out)
+
+	// Exports are for other programs first: only an explicit locale changes
+	// the decimal separator, and never in JSON Lines.
+	decimal := "."
+	if *locale != "" {
+		decimal = units.New(*locale).Decimal
+	}
+
+	var extra []history.Column
+	if intensity != nil {
+		estimator := emissions.NewEstimator(intensity)
+		extra = append(extra, history.Column{
+			Name: "co2_today_kg",
+			Value: func(s history.Sample) float64 {
+				return math.Round(estimator.Update(s.Statistics()).Today*1000) / 1000
+			},
+		})
+	}
+
+	w := history.NewWriter(buf, f, extra...)
+	w.SetDecimal(decimal)
+	for _, s := range samples {
+		if err := w.Write(s); err != nil {
+			return fmt.Errorf("write sample: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+
+	fmt.Fprintln(os.Stderr, cliLanguage().T("export.done", len(samples)))
+	return nil
+}
+
+// parseTime accepts a date or an RFC 3339 timestamp. A date used as the end of
+// a range covers the whole day.
+func parseTime(s string, end bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", s)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
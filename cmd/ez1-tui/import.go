@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/niclaszll/apsystems-ez1-tui/internal/history"
+)
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dir := fs.String("history", history.DefaultDir(), "History directory")
+	format := fs.String("format", "", "Input format: csv, jsonl or tsv (default: from file extension)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: ez1-tui import [flags] FILE...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no input files")
+	}
+
+	store, err := history.Open(*dir)
+	if err != nil {
+		return err
+	}
+
+	lang := cliLanguage()
+	for _, path := range fs.Args() {
+		name := *format
+		if name == "" {
+			name = strings.TrimPrefix(filepath.Ext(path), ".")
+		}
+		f, err := history.ParseFormat(name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		samples, err := history.Read(file, f)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		added, err := store.Merge(samples)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Fprintln(os.Stderr, lang.T("import.done", path, len(samples), added))
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/internal/emissions"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/history"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/influx"
+	"github.com/niclaszll/apsystems-ez1-tui/pkg/apsystems"
+)
+
+// tagFlags collects repeated -tag key=value flags.
+type tagFlags map[string]string
+
+func (t tagFlags) String() string { return fmt.Sprint(map[string]string(t)) }
+
+func (t tagFlags) Set(v string) error {
+	key, value, ok := strings.Cut(v, "=")
+	if !ok || key == "" || value == "" {
+		return fmt.Errorf("expected key=value, got %q", v)
+	}
+	t[key] = value
+	return nil
+}
+
+func runInflux(args []string) error {
+	fs := flag.NewFlagSet("influx", flag.ExitOnError)
+	host := fs.String("host", "", "Microinverter IP address or hostname (required)")
+	port := fs.Int("port", 8050, "Microinverter API port")
+	influxURL := fs.String("url", "http://localhost:8086", "InfluxDB base URL")
+	db := fs.String("db", "ez1", "Database (InfluxDB 1.x) or bucket (InfluxDB 2.x)")
+	rp := fs.String("rp", "", "Retention policy")
+	token := fs.String("token", os.Getenv("INFLUX_TOKEN"), "API token (default: $INFLUX_TOKEN)")
+	measurement := fs.String("measurement", "ez1", "Measurement name")
+	interval := fs.Duration("interval", 30*time.Second, "Polling interval")
+	flushInterval := fs.Duration("flush", time.Minute, "Maximum time between writes")
+	batch := fs.Int("batch", 50, "Number of samples per write")
+	spool := fs.String("spool", filepath.Join(filepath.Dir(history.DefaultDir()), "influx-spool"), "Directory to buffer undelivered samples in (empty to disable)")
+	co2Intensity := fs.Float64("co2-intensity", 0, "Grid carbon intensity in g CO₂/kWh, adds a co2_today_kg field")
+	co2Profile := fs.String("co2-profile", "", "File with a time-of-day grid intensity profile")
+	tags := tagFlags{}
+	fs.Var(tags, "tag", "Additional tag as key=value (repeatable)")
+	fs.Parse(args)
+
+	if *host == "" {
+		fs.Usage()
+		return fmt.Errorf("-host flag is required")
+	}
+
+	intensity, err := loadIntensity(*co2Intensity, *co2Profile)
+	if err != nil {
+		return err
+	}
+	var fields []influx.Field
+	if intensity != nil {
+		estimator := emissions.NewEstimator(intensity)
+		fields = append(fields, influx.Field{
+			Key: "co2_today_kg",
+			Value: func(s history.Sample) float64 {
+				return estimator.Update(s.Statistics()).Today
+			},
+		})
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client := apsystems.NewClient(*host, *port)
+	if _, ok := tags["device_id"]; !ok {
+		infoCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		// An ID the device did not report is left out; an empty tag would
+		// make InfluxDB refuse every line.
+		if info, err := client.GetDeviceInfo(infoCtx); err == nil && info.Data.DeviceID != "" {
+			tags["device_id"] = info.Data.DeviceID
+		}
+		cancel()
+	}
+
+	writer, err := influx.NewWriter(influx.Config{
+		URL:             *influxURL,
+		Database:        *db,
+		RetentionPolicy: *rp,
+		Token:           *token,
+		Measurement:     *measurement,
+		Tags:            tags,
+		BatchSize:       *batch,
+		SpoolDir:        *spool,
+	}, fields...)
+	if err != nil {
+		return err
+	}
+
+	watcher := apsystems.NewWatcher(client,
+		apsystems.WithInterval(apsystems.EndpointOutput, *interval),
+		apsystems.WithDuplicates(apsystems.EndpointOutput),
+	)
+	updates, _ := watcher.Subscribe(16)
+	go watcher.Run(ctx)
+
+	flush := time.NewTicker(*flushInterval)
+	defer flush.Stop()
+
+	// shutdown delivers what is left once polling stops.
+	shutdown := func() error {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return writer.Flush(flushCtx)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return shutdown()
+		case u, ok := <-updates:
+			// The watcher closes the channel when ctx is cancelled.
+			if !ok {
+				return shutdown()
+			}
+			if u.Err != nil {
+				fmt.Fprintf(os.Stderr, "poll %s: %v\n", u.Endpoint, u.Err)
+				continue
+			}
+			if u.Endpoint != apsystems.EndpointOutput {
+				continue
+			}
+			sample := history.FromSnapshot(u.Snapshot)
+			if err := writer.Add(ctx, sample); err != nil {
+				fmt.Fprintf(os.Stderr, "write: %v\n", err)
+			}
+		case <-flush.C:
+			if err := writer.Flush(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "write: %v\n", err)
+			}
+		}
+	}
+}
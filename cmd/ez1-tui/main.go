@@ -1,12 +1,24 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/config"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/emissions"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/gateway"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/history"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/i18n"
 	"github.com/niclaszll/apsystems-ez1-tui/internal/tui"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/units"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/web"
 	"github.com/niclaszll/apsystems-ez1-tui/pkg/apsystems"
 )
 
@@ -17,9 +29,51 @@ var (
 	date    = "unknown"
 )
 
+// commands are the subcommands available besides the default TUI.
+var commands = map[string]func(args []string) error{
+	"bar":      runBar,
+	"check":    runCheck,
+	"doctor":   runDoctor,
+	"export":   runExport,
+	"import":   runImport,
+	"influx":   runInflux,
+	"modbus":   runModbus,
+	"pvoutput": runPVOutput,
+	"serve":    runServe,
+}
+
+// commandOrder is the order of the commands in the usage.
+var commandOrder = []string{"bar", "check", "doctor", "export", "import", "influx", "pvoutput", "modbus", "serve"}
+
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := commands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	host := flag.String("host", "", "Microinverter IP address or hostname (required)")
 	port := flag.Int("port", 8050, "Microinverter API port")
+	token := flag.String("token", os.Getenv("EZ1_TOKEN"), "Gateway access token when connecting through the ez1-tui gateway (default: $EZ1_TOKEN)")
+	co2Intensity := flag.Float64("co2-intensity", 0, "Grid carbon intensity in g CO₂/kWh used to estimate avoided emissions")
+	co2Profile := flag.String("co2-profile", "", "File with a time-of-day grid intensity profile (\"HH:MM grams\" per line)")
+	historyDir := flag.String("history", history.DefaultDir(), "Directory to record samples to (empty to disable)")
+	location := flag.String("location", os.Getenv("EZ1_LOCATION"), "Latitude,longitude of the installation; pauses polling overnight (default: $EZ1_LOCATION)")
+	webAddr := flag.String("web", "", "Also serve the web dashboard on this address, e.g. :8080 (localhost only without -web-token)")
+	var webTokens stringList
+	flag.Var(&webTokens, "web-token", "Token with write access to the web dashboard (repeatable)")
+	record := flag.String("record", "", "Record all requests and responses to this file")
+	replay := flag.String("replay", "", "Replay a recorded session instead of connecting to a device")
+	speed := flag.Float64("speed", 1, "Playback speed of -replay, e.g. 60 to play an hour per minute")
+	configPath := flag.String("config", "", "Configuration file (default: "+config.DefaultPath()+")")
+	theme := flag.String("theme", "", "Color theme: "+strings.Join(tui.ThemeNames(), ", ")+" (default: from the configuration, or auto)")
+	kiosk := flag.Bool("kiosk", false, "Wall display mode: large numbers, rotating pages, no controls except quit")
+	kioskRotate := flag.Duration("kiosk-rotate", tui.DefaultKioskRotate, "Time each page is shown in -kiosk mode")
+	kioskNight := flag.String("kiosk-night", "22:00-06:00", "Hours in which -kiosk dims the display, empty to never dim")
 	showVersion := flag.Bool("version", false, "Show version information")
 	flag.Parse()
 
@@ -30,28 +84,311 @@ func main() {
 		os.Exit(0)
 	}
 
-	if *host == "" {
-		fmt.Println("Error: -host flag is required")
-		fmt.Println("\nUsage:")
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	lang, err := i18n.New(cfg.Language)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *host == "" && *replay == "" {
+		fmt.Println(lang.T("usage.host_required"))
+		fmt.Println("\n" + lang.T("usage.usage"))
 		flag.PrintDefaults()
-		fmt.Println("\nExample:")
+		fmt.Println("\n" + lang.T("usage.example"))
 		fmt.Println("  ez1-tui -host 192.168.1.100")
 		fmt.Println("  ez1-tui -host 192.168.1.100 -port 8050")
+		fmt.Println("  ez1-tui -replay session.jsonl -speed 60")
+		fmt.Println("  ez1-tui -host 192.168.1.100 -kiosk -location 52.52,13.40")
+		fmt.Println("\n" + lang.T("usage.commands"))
+		for _, name := range commandOrder {
+			fmt.Printf("  ez1-tui %-8s %s\n", name, lang.T("usage.command."+name))
+		}
 		os.Exit(1)
 	}
 
-	client := apsystems.NewClient(*host, *port)
+	clientOpts, closeSession, err := sessionOptions(*record, *replay, *speed)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer closeSession()
+	if *replay != "" {
+		*host = "replay"
+	}
+	if *token != "" {
+		clientOpts = append(clientOpts, apsystems.WithToken(*token))
+	}
+	client := apsystems.NewClient(*host, *port, clientOpts...)
 
-	model := tui.NewModel(client)
+	watchOpts, err := daylightOptions(*location)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	opts := []tui.Option{tui.WithWatcherOptions(watchOpts...)}
 
-	p := tea.NewProgram(
-		model,
-		tea.WithAltScreen(),
-		tea.WithMouseCellMotion(),
+	if *theme != "" {
+		cfg.Theme = *theme
+	}
+	t, err := tui.ThemeByName(cfg.Theme)
+	if err == nil {
+		t, err = t.WithColors(cfg.Colors)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	keys, err := tui.NewKeyMap(cfg.Keys)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	format, err := unitsFormatter(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	opts = append(opts,
+		tui.WithTheme(t),
+		tui.WithKeys(keys),
+		tui.WithLayout(tui.Layout{MinWidth: cfg.Layout.MinWidth, MinHeight: cfg.Layout.MinHeight}),
+		tui.WithUnits(format),
+		tui.WithLanguage(lang),
 	)
 
+	if *kiosk {
+		start, end, err := parseHours(*kioskNight)
+		if err != nil {
+			fmt.Printf("Error: -kiosk-night: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, tui.WithKiosk(tui.Kiosk{Rotate: *kioskRotate, NightStart: start, NightEnd: end}))
+	}
+
+	intensity, err := loadIntensity(*co2Intensity, *co2Profile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if intensity != nil {
+		opts = append(opts, tui.WithEmissions(intensity))
+	}
+	// Replayed readings are stamped with the current time, so they are kept
+	// out of the history that export and the PVOutput backfill read.
+	if *historyDir != "" && *replay == "" {
+		store, err := history.Open(*historyDir)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, tui.WithHistory(store))
+	}
+
+	// With the web dashboard enabled, the TUI and the browser share one poller
+	// so the device only sees a single stream of requests.
+	var device tui.Device = client
+	if *webAddr != "" {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		poller := gateway.NewPoller(client, 10*time.Second, watchOpts...)
+		go poller.Run(ctx)
+		device = poller.Cached()
+
+		tokens := map[string]gateway.Access{}
+		for _, t := range webTokens {
+			tokens[t] = gateway.AccessWrite
+		}
+		server := gateway.NewServer(poller, tokens)
+		server.HandlePublic("GET /", web.Handler())
+
+		// Without a token anyone who reaches the dashboard can control the
+		// inverter, so an address without a host only listens locally.
+		// The alternate screen hides anything printed before the TUI
+		// starts, so the warning is shown in the header and again on exit.
+		addr := *webAddr
+		if len(tokens) == 0 {
+			addr = loopbackAddr(addr)
+			opts = append(opts, tui.WithWarning(lang.T("web.no_token", addr)))
+			defer fmt.Fprintf(os.Stderr, "Warning: no -web-token given, anyone who can reach %s can control the inverter\n", addr)
+		}
+		go func() {
+			if err := listenAndServe(ctx, addr, server); err != nil {
+				fmt.Fprintf(os.Stderr, "Error serving web dashboard: %v\n", err)
+			}
+		}()
+	}
+
+	// Other devices can only be opened with a direct connection; the web
+	// dashboard, recordings and replays are tied to one device.
+	if *webAddr == "" && *record == "" && *replay == "" {
+		opts = append(opts, tui.WithDevices(cfg.Devices, func(addr string) (tui.Device, error) {
+			host, port, err := splitAddr(addr, *port)
+			if err != nil {
+				return nil, err
+			}
+			return apsystems.NewClient(host, port, clientOpts...), nil
+		}))
+	}
+
+	model := tui.NewModel(device, opts...)
+
+	programOpts := []tea.ProgramOption{tea.WithAltScreen()}
+	if !*kiosk {
+		programOpts = append(programOpts, tea.WithMouseAllMotion())
+	}
+	p := tea.NewProgram(model, programOpts...)
+
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Error running TUI: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// loadConfig reads the configuration file given with -config, or the one at
+// the default path if it exists.
+func loadConfig(path string) (config.Config, error) {
+	if path != "" {
+		return config.Load(path, true)
+	}
+	return config.Load(config.DefaultPath(), false)
+}
+
+// unitsFormatter builds the formatter for values shown to people from the
+// units section of the configuration.
+func unitsFormatter(cfg config.Config) (units.Formatter, error) {
+	f := units.New(cfg.Units.Locale)
+	switch strings.ToLower(cfg.Units.Clock) {
+	case "":
+	case "12h":
+		f.Hour12 = true
+	case "24h":
+		f.Hour12 = false
+	default:
+		return f, fmt.Errorf("invalid clock %q in configuration (expected 12h or 24h)", cfg.Units.Clock)
+	}
+	if p := cfg.Units.Precision; p != nil {
+		if *p < 0 || *p > 6 {
+			return f, fmt.Errorf("invalid precision %d in configuration (expected 0 to 6)", *p)
+		}
+		f.Precision = *p
+	}
+	f.Scale = !cfg.Units.Fixed
+	return f, nil
+}
+
+// loadLocale returns the formatter and language configured in the default
+// configuration file, for commands without a -config flag.
+func loadLocale() (units.Formatter, i18n.Printer, error) {
+	cfg, err := loadConfig("")
+	if err != nil {
+		return units.Formatter{}, i18n.Printer{}, err
+	}
+	f, err := unitsFormatter(cfg)
+	if err != nil {
+		return f, i18n.Printer{}, err
+	}
+	lang, err := i18n.New(cfg.Language)
+	return f, lang, err
+}
+
+// cliLanguage returns the configured language for the messages of commands
+// that otherwise ignore the configuration. A broken configuration falls
+// back to the environment instead of failing the command.
+func cliLanguage() i18n.Printer {
+	cfg, _ := loadConfig("")
+	lang, err := i18n.New(cfg.Language)
+	if err != nil {
+		lang, _ = i18n.New("")
+	}
+	return lang
+}
+
+// daylightOptions parses a "lat,lon" location into watcher options that
+// pause polling overnight. An empty location polls around the clock.
+func daylightOptions(location string) ([]apsystems.WatcherOption, error) {
+	if location == "" {
+		return nil, nil
+	}
+	lat, lon, err := parseLocation(location)
+	if err != nil {
+		return nil, err
+	}
+	return []apsystems.WatcherOption{apsystems.WithDaylight(lat, lon)}, nil
+}
+
+// loopbackAddr binds a listen address without a host, such as ":8080", to
+// 127.0.0.1. Explicit hosts are kept.
+func loopbackAddr(addr string) string {
+	if host, port, err := net.SplitHostPort(addr); err == nil && host == "" {
+		return net.JoinHostPort("127.0.0.1", port)
+	}
+	return addr
+}
+
+// splitAddr splits "host[:port]", using defaultPort if there is no port.
+func splitAddr(addr string, defaultPort int) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, defaultPort, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid port in %q", addr)
+	}
+	return host, port, nil
+}
+
+// parseLocation parses "lat,lon" in degrees.
+func parseLocation(location string) (lat, lon float64, err error) {
+	latStr, lonStr, ok := strings.Cut(location, ",")
+	lat, latErr := strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+	lon, lonErr := strconv.ParseFloat(strings.TrimSpace(lonStr), 64)
+	if !ok || latErr != nil || lonErr != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("invalid location %q (expected latitude,longitude, e.g. 52.52,13.40)", location)
+	}
+	return lat, lon, nil
+}
+
+// parseHours parses a range of times of day like "22:00-06:00" into offsets
+// from midnight. An empty range returns zero for both.
+func parseHours(hours string) (start, end time.Duration, err error) {
+	if hours == "" {
+		return 0, 0, nil
+	}
+	from, to, ok := strings.Cut(hours, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid range %q (expected e.g. 22:00-06:00)", hours)
+	}
+	for _, t := range []struct {
+		s string
+		d *time.Duration
+	}{{from, &start}, {to, &end}} {
+		parsed, err := time.Parse("15:04", strings.TrimSpace(t.s))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid time %q (expected HH:MM)", t.s)
+		}
+		*t.d = time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute
+	}
+	return start, end, nil
+}
+
+// loadIntensity returns the configured grid intensity, or nil if CO₂
+// estimation is disabled. A profile takes precedence over a static value.
+func loadIntensity(static float64, profilePath string) (emissions.Intensity, error) {
+	if profilePath != "" {
+		return emissions.LoadProfile(profilePath)
+	}
+	if static < 0 {
+		return nil, fmt.Errorf("-co2-intensity must not be negative")
+	}
+	if static > 0 {
+		return emissions.Static(static), nil
+	}
+	return nil, nil
+}
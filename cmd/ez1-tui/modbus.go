@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/internal/modbus"
+	"github.com/niclaszll/apsystems-ez1-tui/pkg/apsystems"
+)
+
+func runModbus(args []string) error {
+	fs := flag.NewFlagSet("modbus", flag.ExitOnError)
+	host := fs.String("host", "", "Microinverter IP address or hostname (required)")
+	port := fs.Int("port", 8050, "Microinverter API port")
+	listen := fs.String("listen", ":502", "Modbus TCP listen address; an address without a host only listens on 127.0.0.1")
+	unit := fs.Uint("unit", 1, "Modbus unit ID")
+	interval := fs.Duration("interval", 10*time.Second, "Polling interval")
+	fs.Parse(args)
+
+	if *host == "" {
+		fs.Usage()
+		return fmt.Errorf("-host flag is required")
+	}
+	if *unit < 1 || *unit > 247 {
+		return fmt.Errorf("-unit must be between 1 and 247")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	device := modbus.NewDevice(apsystems.NewClient(*host, *port), uint8(*unit))
+	go device.Poll(ctx, *interval, func(err error) {
+		fmt.Fprintf(os.Stderr, "poll: %v\n", err)
+	})
+
+	// Modbus has no authentication and anyone who reaches the port can
+	// switch the inverter, so an address without a host only listens
+	// locally.
+	ln, err := net.Listen("tcp", loopbackAddr(*listen))
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	if addr, ok := ln.Addr().(*net.TCPAddr); ok && !addr.IP.IsLoopback() {
+		fmt.Fprintf(os.Stderr, "Warning: Modbus has no authentication, anyone who can reach %s can control the inverter\n", ln.Addr())
+	}
+	fmt.Fprintln(os.Stderr, cliLanguage().T("modbus.serving", modbus.BaseAddress, ln.Addr()))
+
+	server := &modbus.Server{Handler: device}
+	return server.Serve(ctx, ln)
+}
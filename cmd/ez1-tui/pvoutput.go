@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/internal/history"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/pvoutput"
+	"github.com/niclaszll/apsystems-ez1-tui/pkg/apsystems"
+)
+
+func runPVOutput(args []string) error {
+	fs := flag.NewFlagSet("pvoutput", flag.ExitOnError)
+	host := fs.String("host", "", "Microinverter IP address or hostname (required unless -poll=false)")
+	port := fs.Int("port", 8050, "Microinverter API port")
+	poll := fs.Bool("poll", true, "Poll the microinverter and record samples; disable if the TUI is already recording")
+	interval := fs.Duration("interval", time.Minute, "Polling interval")
+	dir := fs.String("history", history.DefaultDir(), "History directory")
+	baseURL := fs.String("url", pvoutput.DefaultBaseURL, "PVOutput base URL")
+	apiKey := fs.String("api-key", os.Getenv("PVOUTPUT_API_KEY"), "PVOutput API key (default: $PVOUTPUT_API_KEY)")
+	systemID := fs.String("system-id", os.Getenv("PVOUTPUT_SYSTEM_ID"), "PVOutput system ID (default: $PVOUTPUT_SYSTEM_ID)")
+	batch := fs.Int("batch", 30, "Statuses per batch request (30, or 100 with donation)")
+	once := fs.Bool("once", false, "Upload pending data once and exit")
+	fs.Parse(args)
+
+	if *apiKey == "" || *systemID == "" {
+		return fmt.Errorf("-api-key and -system-id are required")
+	}
+	if *poll && !*once && *host == "" {
+		return fmt.Errorf("-host flag is required when polling")
+	}
+
+	store, err := history.Open(*dir)
+	if err != nil {
+		return err
+	}
+	client := pvoutput.NewClient(pvoutput.Config{
+		BaseURL:   *baseURL,
+		APIKey:    *apiKey,
+		SystemID:  *systemID,
+		BatchSize: *batch,
+	})
+	uploader, err := pvoutput.NewUploader(client, store, filepath.Join(filepath.Dir(*dir), "pvoutput-state.json"))
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *once {
+		return uploader.Sync(ctx, time.Now())
+	}
+
+	// Without polling the channel stays nil and only uploads run.
+	var updates <-chan apsystems.Update
+	if *poll {
+		watcher := apsystems.NewWatcher(apsystems.NewClient(*host, *port),
+			apsystems.WithInterval(apsystems.EndpointOutput, *interval),
+			apsystems.WithDuplicates(apsystems.EndpointOutput),
+		)
+		updates, _ = watcher.Subscribe(16)
+		go watcher.Run(ctx)
+	}
+
+	uploadTicker := time.NewTicker(pvoutput.Interval)
+	defer uploadTicker.Stop()
+	var pausedUntil time.Time
+
+	record := func(u apsystems.Update) {
+		if u.Err != nil {
+			fmt.Fprintf(os.Stderr, "poll %s: %v\n", u.Endpoint, u.Err)
+			return
+		}
+		if u.Endpoint != apsystems.EndpointOutput {
+			return
+		}
+		sample := history.FromSnapshot(u.Snapshot)
+		if err := store.Append(sample); err != nil {
+			fmt.Fprintf(os.Stderr, "record: %v\n", err)
+		}
+	}
+	upload := func() {
+		if time.Now().Before(pausedUntil) {
+			return
+		}
+		err := uploader.Sync(ctx, time.Now())
+		if rle, ok := pvoutput.IsRateLimited(err); ok {
+			pausedUntil = rle.Reset
+			if pausedUntil.IsZero() {
+				pausedUntil = time.Now().Add(time.Hour)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "upload: %v\n", err)
+		}
+	}
+
+	upload()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case u, ok := <-updates:
+			// The watcher closes the channel when ctx is cancelled.
+			if !ok {
+				return nil
+			}
+			record(u)
+		case <-uploadTicker.C:
+			upload()
+		}
+	}
+}
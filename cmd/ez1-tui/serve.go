@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/internal/gateway"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/web"
+	"github.com/niclaszll/apsystems-ez1-tui/pkg/apsystems"
+)
+
+// stringList collects repeated string flags.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	host := fs.String("host", "", "Microinverter IP address or hostname (required)")
+	port := fs.Int("port", 8050, "Microinverter API port")
+	listen := fs.String("listen", ":8050", "HTTP listen address; without a token, an address without a host only listens on 127.0.0.1")
+	interval := fs.Duration("interval", 10*time.Second, "Polling interval for output data")
+	webUI := fs.Bool("web", true, "Serve the web dashboard at /")
+	location := fs.String("location", os.Getenv("EZ1_LOCATION"), "Latitude,longitude of the installation; pauses polling overnight (default: $EZ1_LOCATION)")
+	var writeTokens, readTokens stringList
+	fs.Var(&writeTokens, "token", "Token with read and write access (repeatable)")
+	fs.Var(&readTokens, "read-token", "Token with read-only access (repeatable)")
+	fs.Parse(args)
+
+	if *host == "" {
+		fs.Usage()
+		return fmt.Errorf("-host flag is required")
+	}
+
+	tokens := map[string]gateway.Access{}
+	for _, t := range readTokens {
+		tokens[t] = gateway.AccessRead
+	}
+	for _, t := range writeTokens {
+		tokens[t] = gateway.AccessWrite
+	}
+	watchOpts, err := daylightOptions(*location)
+	if err != nil {
+		return err
+	}
+	// Without a token anyone who reaches the gateway can control the
+	// inverter, so an address without a host only listens locally.
+	addr := *listen
+	if len(tokens) == 0 {
+		addr = loopbackAddr(addr)
+		fmt.Fprintf(os.Stderr, "Warning: no tokens configured, anyone who can reach %s can control the inverter\n", addr)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	poller := gateway.NewPoller(apsystems.NewClient(*host, *port), *interval, watchOpts...)
+	go poller.Run(ctx)
+
+	handler := gateway.NewServer(poller, tokens)
+	if *webUI {
+		handler.HandlePublic("GET /", web.Handler())
+	}
+
+	fmt.Fprintln(os.Stderr, cliLanguage().T("serve.serving", *host, addr))
+	return listenAndServe(ctx, addr, handler)
+}
+
+// listenAndServe runs an HTTP server until ctx is cancelled and then shuts it
+// down gracefully.
+func listenAndServe(ctx context.Context, addr string, handler http.Handler) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
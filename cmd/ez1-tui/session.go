@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/pkg/apsystems"
+)
+
+// sessionOptions returns client options that record the session to record
+// or replay it from replay instead of talking to a device. The returned
+// function closes the recording.
+func sessionOptions(record, replay string, speed float64) ([]apsystems.ClientOption, func(), error) {
+	switch {
+	case record != "" && replay != "":
+		return nil, nil, errors.New("-record and -replay cannot be combined")
+
+	case replay != "":
+		f, err := os.Open(replay)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer f.Close()
+		exchanges, err := apsystems.ReadRecording(f)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read %s: %w", replay, err)
+		}
+		transport := apsystems.NewReplayer(exchanges, speed)
+		return []apsystems.ClientOption{apsystems.WithHTTPClient(&http.Client{Transport: transport})}, func() {}, nil
+
+	case record != "":
+		f, err := os.Create(record)
+		if err != nil {
+			return nil, nil, err
+		}
+		transport := apsystems.NewRecorder(f, nil)
+		httpClient := &http.Client{Transport: transport, Timeout: 10 * time.Second}
+		return []apsystems.ClientOption{apsystems.WithHTTPClient(httpClient)}, func() { f.Close() }, nil
+	}
+	return nil, func() {}, nil
+}
@@ -0,0 +1,317 @@
+// Package check implements a monitoring plugin for Nagios, Icinga and other
+// systems following the monitoring plugin guidelines.
+package check
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/internal/i18n"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/units"
+	"github.com/niclaszll/apsystems-ez1-tui/pkg/apsystems"
+)
+
+// State is a plugin result; its value is the exit code.
+type State int
+
+const (
+	OK State = iota
+	Warning
+	Critical
+	Unknown
+)
+
+func (s State) String() string {
+	switch s {
+	case OK:
+		return "OK"
+	case Warning:
+		return "WARNING"
+	case Critical:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ParseState parses a state name as used for the -alarm and -off flags.
+func ParseState(name string) (State, error) {
+	for _, s := range []State{OK, Warning, Critical, Unknown} {
+		if strings.EqualFold(name, s.String()) {
+			return s, nil
+		}
+	}
+	return Unknown, fmt.Errorf("invalid state %q (expected ok, warning, critical or unknown)", name)
+}
+
+// severity orders states by importance: a warning outweighs an unknown
+// result of another check, a critical result outweighs everything.
+var severity = map[State]int{OK: 0, Unknown: 1, Warning: 2, Critical: 3}
+
+func worst(a, b State) State {
+	if severity[b] > severity[a] {
+		return b
+	}
+	return a
+}
+
+// Window reports whether the inverter is expected to produce at t.
+type Window func(t time.Time) bool
+
+// Hours is a window between two times of day, given as offsets from
+// midnight. It may span midnight.
+func Hours(start, end time.Duration) Window {
+	return func(t time.Time) bool {
+		y, m, d := t.Date()
+		tod := t.Sub(time.Date(y, m, d, 0, 0, 0, 0, t.Location()))
+		if start <= end {
+			return tod >= start && tod < end
+		}
+		return tod >= start || tod < end
+	}
+}
+
+// Sun is the window between sunrise and sunset at the given coordinates.
+func Sun(lat, lon float64) Window {
+	return func(t time.Time) bool {
+		rise, set, up, ok := apsystems.SunTimes(t, lat, lon)
+		if !ok {
+			return up
+		}
+		return !t.Before(rise) && t.Before(set)
+	}
+}
+
+// Thresholds decide the state of a check. Zero values disable a threshold.
+type Thresholds struct {
+	// WarnPower and CritPower are the minimum total power in W while the
+	// window is open.
+	WarnPower, CritPower int
+	// WarnLatency and CritLatency apply to the slowest request.
+	WarnLatency, CritLatency time.Duration
+	// MinLimit is the expected minimum power limit in W; a lower limit is
+	// a warning.
+	MinLimit int
+	// Alarm is the state if any alarm is active, Off the state if the
+	// inverter is switched off.
+	Alarm, Off State
+}
+
+type Config struct {
+	Host    string
+	Port    int
+	Token   string
+	Timeout time.Duration
+	// Units formats the readings in the plugin output; performance data
+	// always uses plain numbers. The zero value follows the environment.
+	Units units.Formatter
+	// Lang is the language of the plugin output; the states stay English
+	// as monitoring systems parse them.
+	Lang i18n.Printer
+	// Window is when the inverter is expected to produce. Outside of it
+	// the power thresholds do not apply and an unreachable inverter is
+	// fine, as it shuts down without sunlight. Nil means always.
+	Window Window
+	Thresholds
+}
+
+// Result is the outcome of a check run.
+type Result struct {
+	State State
+	// Summary lists the problems, or the readings if there are none.
+	Summary []string
+	// Details are shown as long output, one line each.
+	Details []string
+	Perf    []Perf
+}
+
+func (r *Result) add(s State, summary string) {
+	r.State = worst(r.State, s)
+	if s != OK {
+		r.Summary = append(r.Summary, summary)
+	}
+	r.Details = append(r.Details, fmt.Sprintf("[%s] %s", s, summary))
+}
+
+// Run reads the device and evaluates the thresholds.
+func Run(ctx context.Context, cfg Config) Result {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Units == (units.Formatter{}) {
+		cfg.Units = units.New("")
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	var opts []apsystems.ClientOption
+	if cfg.Token != "" {
+		opts = append(opts, apsystems.WithToken(cfg.Token))
+	}
+	client := apsystems.NewClient(cfg.Host, cfg.Port, opts...)
+	daylight := cfg.Window == nil || cfg.Window(time.Now())
+
+	u, t := cfg.Units, cfg.Lang.T
+	var r Result
+	var slowest time.Duration
+	timed := func(read func() error) error {
+		start := time.Now()
+		err := read()
+		slowest = max(slowest, time.Since(start))
+		return err
+	}
+
+	var output *apsystems.OutputData
+	err := timed(func() (err error) {
+		output, err = client.GetOutputData(ctx)
+		return err
+	})
+	if err != nil {
+		if !daylight {
+			r.add(OK, t("check.offline_night"))
+			r.Summary = []string{t("check.offline_night")}
+			return r
+		}
+		r.add(Critical, t("check.unreachable", err))
+		return r
+	}
+	stats := apsystems.NewStatistics(output, time.Now())
+	r.checkPower(cfg, stats, daylight)
+
+	if cfg.Alarm != OK {
+		var alarm *apsystems.AlarmInfo
+		err := timed(func() (err error) {
+			alarm, err = client.GetAlarmInfo(ctx)
+			return err
+		})
+		r.checkAlarms(cfg, alarm, err)
+	}
+	if cfg.Off != OK {
+		var status *apsystems.PowerStatus
+		err := timed(func() (err error) {
+			status, err = client.GetDevicePowerStatus(ctx)
+			return err
+		})
+		switch {
+		case err != nil:
+			r.add(Unknown, t("check.power_status_error", err))
+		case !status.Valid("status"):
+			r.add(Unknown, t("check.power_status_missing"))
+		case status.Data.Status == apsystems.PowerOff:
+			r.add(cfg.Off, t("check.switched_off"))
+		default:
+			r.add(OK, t("check.switched_on"))
+		}
+	}
+	if cfg.MinLimit > 0 {
+		var limit *apsystems.PowerLimit
+		err := timed(func() (err error) {
+			limit, err = client.GetMaxPower(ctx)
+			return err
+		})
+		switch {
+		case err != nil:
+			r.add(Unknown, t("check.limit_error", err))
+		case int(limit.Data.MaxPower) < cfg.MinLimit:
+			r.add(Warning, t("check.limit_low", u.Power(float64(limit.Data.MaxPower)), u.Power(float64(cfg.MinLimit))))
+		default:
+			r.add(OK, t("check.limit", u.Power(float64(limit.Data.MaxPower))))
+		}
+	}
+
+	latency := slowest.Round(time.Millisecond).Seconds()
+	took := t("check.latency", u.Number(latency, 2))
+	switch {
+	case cfg.CritLatency > 0 && slowest >= cfg.CritLatency:
+		r.add(Critical, took)
+	case cfg.WarnLatency > 0 && slowest >= cfg.WarnLatency:
+		r.add(Warning, took)
+	default:
+		r.add(OK, took)
+	}
+	r.Perf = append(r.Perf, Perf{
+		Label: "latency", Value: latency, Unit: "s",
+		Warn: upper(cfg.WarnLatency.Seconds()), Crit: upper(cfg.CritLatency.Seconds()), Min: "0",
+	})
+
+	if r.State == OK {
+		r.Summary = []string{t("check.summary", u.Power(float64(stats.TotalPower)), u.Energy(stats.TotalEnergyToday))}
+	}
+	return r
+}
+
+func (r *Result) checkPower(cfg Config, stats *apsystems.Statistics, daylight bool) {
+	t := cfg.Lang.T
+	power := func(watts int) string { return cfg.Units.Power(float64(watts)) }
+	switch {
+	case !stats.Valid("p1") || !stats.Valid("p2"):
+		r.add(Unknown, t("check.power_missing", strings.Join(stats.Invalid(), ", ")))
+	case !daylight:
+		r.add(OK, t("check.power_night", power(stats.TotalPower)))
+	case cfg.CritPower > 0 && stats.TotalPower < cfg.CritPower:
+		r.add(Critical, t("check.power_low", power(stats.TotalPower), power(cfg.CritPower)))
+	case cfg.WarnPower > 0 && stats.TotalPower < cfg.WarnPower:
+		r.add(Warning, t("check.power_low", power(stats.TotalPower), power(cfg.WarnPower)))
+	default:
+		r.add(OK, t("check.power", power(stats.TotalPower)))
+	}
+
+	// Power thresholds are minimums, which the range syntax writes as
+	// "n:"; they are left out at night when they are not checked.
+	var warn, crit string
+	if daylight {
+		warn, crit = lower(cfg.WarnPower), lower(cfg.CritPower)
+	}
+	r.Perf = append(r.Perf,
+		Perf{Label: "power", Value: float64(stats.TotalPower), Unit: "W", Warn: warn, Crit: crit, Min: "0"},
+		Perf{Label: "power_1", Value: float64(stats.Power1), Unit: "W", Min: "0"},
+		Perf{Label: "power_2", Value: float64(stats.Power2), Unit: "W", Min: "0"},
+		Perf{Label: "energy_today", Value: stats.TotalEnergyToday, Unit: "kWh", Min: "0"},
+	)
+}
+
+func (r *Result) checkAlarms(cfg Config, alarm *apsystems.AlarmInfo, err error) {
+	t := cfg.Lang.T
+	if err != nil {
+		r.add(Unknown, t("check.alarms_error", err))
+		return
+	}
+	d := alarm.Data
+	var active []string
+	for _, a := range []struct {
+		field string
+		state apsystems.AlarmState
+	}{
+		{"og", d.Og},
+		{"isce1", d.Isce1},
+		{"isce2", d.Isce2},
+		{"oe", d.Oe},
+	} {
+		if alarm.Valid(a.field) && a.state.Active() {
+			active = append(active, t("check.alarm."+a.field))
+		}
+	}
+	if len(active) > 0 {
+		r.add(cfg.Alarm, t("check.alarm", strings.Join(active, ", ")))
+		return
+	}
+	r.add(OK, t("check.no_alarms"))
+}
+
+// lower and upper turn thresholds into the range syntax of perfdata; a
+// disabled threshold is left empty.
+func lower(watts int) string {
+	if watts <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d:", watts)
+}
+
+func upper(seconds float64) string {
+	if seconds <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%g", seconds)
+}
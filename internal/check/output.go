@@ -0,0 +1,44 @@
+package check
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Perf is a performance data value. Warn and Crit are ranges in the plugin
+// threshold syntax, e.g. "10:" for at least 10.
+type Perf struct {
+	Label      string
+	Value      float64
+	Unit       string
+	Warn, Crit string
+	Min, Max   string
+}
+
+func (p Perf) String() string {
+	s := fmt.Sprintf("%s=%s%s;%s;%s;%s;%s", p.Label, strconv.FormatFloat(p.Value, 'f', -1, 64), p.Unit, p.Warn, p.Crit, p.Min, p.Max)
+	return strings.TrimRight(s, ";")
+}
+
+// Write prints the result in the plugin output format: a status line with
+// performance data, followed by one line per check.
+func (r Result) Write(w io.Writer) error {
+	var b strings.Builder
+	// "|" separates performance data and must not appear in the text.
+	fmt.Fprintf(&b, "EZ1 %s - %s", r.State, strings.ReplaceAll(strings.Join(r.Summary, ", "), "|", "/"))
+	if len(r.Perf) > 0 {
+		var perf []string
+		for _, p := range r.Perf {
+			perf = append(perf, p.String())
+		}
+		b.WriteString(" | " + strings.Join(perf, " "))
+	}
+	b.WriteString("\n")
+	for _, line := range r.Details {
+		b.WriteString(strings.ReplaceAll(line, "|", "/") + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
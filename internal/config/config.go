@@ -0,0 +1,78 @@
+// Package config loads the optional ez1-tui configuration file.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Config holds settings that are not worth a command-line flag each.
+type Config struct {
+	// Language is the language of the TUI and CLI, e.g. "de"; the default
+	// follows LC_ALL, LC_MESSAGES and LANG.
+	Language string `json:"language,omitempty"`
+	// Theme is the name of a built-in theme, see tui.ThemeNames.
+	Theme string `json:"theme,omitempty"`
+	// Colors overrides colors of the theme by role, e.g.
+	// {"accent": "#FF8800"}.
+	Colors map[string]string `json:"colors,omitempty"`
+	// Keys replaces the keys of actions, e.g. {"power_off": ["x"]}, see
+	// tui.KeyActions.
+	Keys map[string][]string `json:"keys,omitempty"`
+	// Devices names devices for the switch device command, e.g.
+	// {"garage": "192.168.1.101:8050"}.
+	Devices map[string]string `json:"devices,omitempty"`
+	// Layout sets the terminal size from which on the dashboard shows all
+	// panels at once; -1 disables this.
+	Layout struct {
+		MinWidth  int `json:"min_width,omitempty"`
+		MinHeight int `json:"min_height,omitempty"`
+	} `json:"layout"`
+	// Units sets how values are shown. Locale defaults to LC_ALL,
+	// LC_NUMERIC and LANG, e.g. "de_DE"; Clock is "12h" or "24h";
+	// Precision is the number of decimals instead of the default per unit;
+	// Fixed always shows W and kWh instead of scaling to kW and MWh.
+	Units struct {
+		Locale    string `json:"locale,omitempty"`
+		Clock     string `json:"clock,omitempty"`
+		Precision *int   `json:"precision,omitempty"`
+		Fixed     bool   `json:"fixed,omitempty"`
+	} `json:"units"`
+}
+
+// DefaultPath returns $XDG_CONFIG_HOME/ez1-tui/config.json, falling back to
+// ~/.config.
+func DefaultPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "ez1-tui", "config.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "ez1-tui", "config.json")
+}
+
+// Load reads the configuration at path. A missing file yields the zero
+// Config unless required is set.
+func Load(path string, required bool) (Config, error) {
+	var c Config
+	if path == "" {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return c, nil
+}
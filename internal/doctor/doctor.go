@@ -0,0 +1,375 @@
+// Package doctor runs connectivity and API checks against an EZ1 and
+// explains failures.
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+type Status string
+
+const (
+	Pass Status = "pass"
+	Warn Status = "warn"
+	Fail Status = "fail"
+	Skip Status = "skip"
+)
+
+// Check is the result of one diagnostic step.
+type Check struct {
+	Name       string  `json:"name"`
+	Status     Status  `json:"status"`
+	Detail     string  `json:"detail,omitempty"`
+	Hint       string  `json:"hint,omitempty"`
+	DurationMS float64 `json:"duration_ms,omitempty"`
+}
+
+// Report collects all checks of a run together with details about the
+// environment, for attaching to bug reports.
+type Report struct {
+	Host     string    `json:"host"`
+	Port     int       `json:"port"`
+	Version  string    `json:"version"`
+	OS       string    `json:"os"`
+	Arch     string    `json:"arch"`
+	Time     time.Time `json:"time"`
+	Firmware string    `json:"firmware,omitempty"`
+	Checks   []Check   `json:"checks"`
+}
+
+// Failed reports whether any check failed.
+func (r Report) Failed() bool {
+	return slices.ContainsFunc(r.Checks, func(c Check) bool { return c.Status == Fail })
+}
+
+type Config struct {
+	Host    string
+	Port    int
+	Token   string
+	Timeout time.Duration
+	Version string // of ez1-tui, included in the report
+}
+
+// endpoint describes an API endpoint and the fields its data object must
+// contain.
+type endpoint struct {
+	path   string
+	fields []string
+}
+
+var endpoints = []endpoint{
+	{"/getDeviceInfo", []string{"deviceId", "devVer", "ssid", "ipAddr", "minPower", "maxPower"}},
+	{"/getOutputData", []string{"p1", "e1", "te1", "p2", "e2", "te2"}},
+	{"/getAlarm", []string{"og", "isce1", "isce2", "oe"}},
+	{"/getOnOff", []string{"status"}},
+	{"/getMaxPower", []string{"maxPower"}},
+}
+
+// latencySamples is the number of extra requests used to measure latency.
+const latencySamples = 5
+
+type runner struct {
+	cfg    Config
+	client *http.Client
+	report Report
+	addrs  []net.IP
+}
+
+// Run performs all checks. Later checks are skipped when the device cannot
+// be reached at all.
+func Run(ctx context.Context, cfg Config) Report {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	r := &runner{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		report: Report{
+			Host:    cfg.Host,
+			Port:    cfg.Port,
+			Version: cfg.Version,
+			OS:      runtime.GOOS,
+			Arch:    runtime.GOARCH,
+			Time:    time.Now().UTC(),
+		},
+	}
+
+	reachable := r.resolve(ctx)
+	if reachable {
+		reachable = r.connect(ctx)
+	} else {
+		r.add(Check{Name: "tcp", Status: Skip, Detail: "host not resolved"})
+	}
+	var info map[string]json.RawMessage
+	for _, ep := range endpoints {
+		if !reachable {
+			r.add(Check{Name: "api " + ep.path, Status: Skip, Detail: "device not reachable"})
+			continue
+		}
+		data := r.checkEndpoint(ctx, ep)
+		if ep.path == "/getDeviceInfo" {
+			info = data
+		}
+	}
+	if !reachable {
+		r.add(Check{Name: "latency", Status: Skip, Detail: "device not reachable"})
+		r.add(Check{Name: "firmware", Status: Skip, Detail: "device not reachable"})
+		r.add(Check{Name: "address", Status: Skip, Detail: "device not reachable"})
+		return r.report
+	}
+	r.latency(ctx)
+	r.firmware(info)
+	r.address(info)
+	return r.report
+}
+
+func (r *runner) add(c Check) {
+	r.report.Checks = append(r.report.Checks, c)
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func (r *runner) resolve(ctx context.Context) bool {
+	if ip := net.ParseIP(r.cfg.Host); ip != nil {
+		r.addrs = []net.IP{ip}
+		r.add(r.checkAddressRange(Check{Name: "resolve", Status: Pass, Detail: "IP address " + ip.String()}))
+		return true
+	}
+
+	start := time.Now()
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", r.cfg.Host)
+	took := time.Since(start)
+	if err != nil {
+		r.add(Check{
+			Name:       "resolve",
+			Status:     Fail,
+			Detail:     err.Error(),
+			Hint:       "The hostname cannot be resolved. Use the IP address shown in the APsystems app under Settings → Local Mode.",
+			DurationMS: ms(took),
+		})
+		return false
+	}
+	r.addrs = ips
+	r.add(r.checkAddressRange(Check{Name: "resolve", Status: Pass, Detail: fmt.Sprint(ips), DurationMS: ms(took)}))
+	return true
+}
+
+// checkAddressRange downgrades c to a warning if no address is in a local
+// network; the EZ1 local API is only reachable on the LAN.
+func (r *runner) checkAddressRange(c Check) Check {
+	for _, ip := range r.addrs {
+		if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+			return c
+		}
+	}
+	c.Status = Warn
+	c.Hint = "The address is not in a local network. The local API only works on the same network as the inverter."
+	return c
+}
+
+func (r *runner) connect(ctx context.Context) bool {
+	addr := net.JoinHostPort(r.cfg.Host, strconv.Itoa(r.cfg.Port))
+	dialer := net.Dialer{Timeout: r.cfg.Timeout}
+
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	took := time.Since(start)
+	if err == nil {
+		conn.Close()
+		r.add(Check{Name: "tcp", Status: Pass, Detail: "connected to " + addr, DurationMS: ms(took)})
+		return true
+	}
+
+	c := Check{Name: "tcp", Status: Fail, Detail: err.Error(), DurationMS: ms(took)}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		c.Hint = fmt.Sprintf("The device answers but nothing listens on port %d. Enable local mode with \"Continuous\" in the APsystems app (Settings → Local Mode); the API port is 8050.", r.cfg.Port)
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		c.Hint = "No route to the device. Make sure this computer is on the same network as the inverter."
+	case errors.As(err, &netErr) && netErr.Timeout():
+		c.Hint = "No answer. The inverter shuts down without sun; otherwise check that it is connected to Wi-Fi and that the IP address has not changed."
+	default:
+		c.Hint = "Check the IP address and that the inverter is connected to Wi-Fi."
+	}
+	r.add(c)
+	return false
+}
+
+// get requests path and returns the response status, body and duration.
+func (r *runner) get(ctx context.Context, path string) (*http.Response, []byte, time.Duration, error) {
+	url := "http://" + net.JoinHostPort(r.cfg.Host, strconv.Itoa(r.cfg.Port)) + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if r.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.cfg.Token)
+	}
+
+	// Durations use the monotonic clock, so wall clock adjustments during
+	// the run cannot distort them.
+	start := time.Now()
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, nil, time.Since(start), err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return resp, body, time.Since(start), err
+}
+
+func (r *runner) checkEndpoint(ctx context.Context, ep endpoint) map[string]json.RawMessage {
+	c := Check{Name: "api " + ep.path}
+	resp, body, took, err := r.get(ctx, ep.path)
+	c.DurationMS = ms(took)
+	if err != nil {
+		c.Status, c.Detail = Fail, err.Error()
+		c.Hint = "The port is open but the request failed. The inverter may be busy; try again in a minute."
+		r.add(c)
+		return nil
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		c.Status, c.Detail = Fail, resp.Status
+		c.Hint = "Access denied. This looks like an ez1-tui gateway; pass a token with -token."
+		r.add(c)
+		return nil
+	case resp.StatusCode == http.StatusNotFound:
+		c.Status, c.Detail = Fail, resp.Status
+		c.Hint = "Endpoint not found. Check that the host and port belong to the EZ1 local API (port 8050)."
+		r.add(c)
+		return nil
+	case resp.StatusCode != http.StatusOK:
+		c.Status, c.Detail = Fail, resp.Status
+		r.add(c)
+		return nil
+	}
+
+	var envelope struct {
+		Data     map[string]json.RawMessage `json:"data"`
+		Message  string                     `json:"message"`
+		DeviceID string                     `json:"deviceId"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		c.Status, c.Detail = Fail, "invalid JSON: "+err.Error()
+		c.Hint = "The response is not JSON. Another service may be running on this port."
+		r.add(c)
+		return nil
+	}
+
+	var missing []string
+	for _, f := range ep.fields {
+		if _, ok := envelope.Data[f]; !ok {
+			missing = append(missing, f)
+		}
+	}
+	switch {
+	case envelope.Data == nil:
+		c.Status, c.Detail = Fail, "response has no data object"
+		c.Hint = "Unexpected response format. Please attach the JSON report to a bug report."
+	case len(missing) > 0:
+		c.Status, c.Detail = Warn, fmt.Sprintf("missing fields %v", missing)
+		c.Hint = "The firmware returns a different schema. Please attach the JSON report to a bug report."
+	case envelope.Message != "" && envelope.Message != "SUCCESS":
+		c.Status, c.Detail = Warn, "device message "+strconv.Quote(envelope.Message)
+	default:
+		c.Status, c.Detail = Pass, resp.Status
+		if resp.Header.Get("X-Data-Age") != "" {
+			c.Detail += " (via ez1-tui gateway)"
+		}
+	}
+	r.add(c)
+	return envelope.Data
+}
+
+func (r *runner) latency(ctx context.Context) {
+	var samples []time.Duration
+	var failures int
+	for range latencySamples {
+		_, _, took, err := r.get(ctx, "/getOutputData")
+		if err != nil {
+			failures++
+			continue
+		}
+		samples = append(samples, took)
+	}
+
+	c := Check{Name: "latency"}
+	if len(samples) == 0 {
+		c.Status, c.Detail = Fail, fmt.Sprintf("all %d requests failed", latencySamples)
+		c.Hint = "The connection is unstable. Check the Wi-Fi signal strength at the inverter."
+		r.add(c)
+		return
+	}
+	slices.Sort(samples)
+	median := samples[len(samples)/2]
+	c.Detail = fmt.Sprintf("min %s, median %s, max %s, %d/%d failed",
+		samples[0].Round(time.Millisecond), median.Round(time.Millisecond), samples[len(samples)-1].Round(time.Millisecond),
+		failures, latencySamples)
+	switch {
+	case failures > 0:
+		c.Status = Warn
+		c.Hint = "Some requests failed. The Wi-Fi signal at the inverter may be weak."
+	case median > time.Second:
+		c.Status = Warn
+		c.Hint = "Responses are slow. The Wi-Fi signal at the inverter is probably weak; a repeater can help."
+	default:
+		c.Status = Pass
+	}
+	r.add(c)
+}
+
+func stringField(data map[string]json.RawMessage, key string) string {
+	var s string
+	if raw, ok := data[key]; ok {
+		json.Unmarshal(raw, &s)
+	}
+	return s
+}
+
+func (r *runner) firmware(info map[string]json.RawMessage) {
+	c := Check{Name: "firmware"}
+	version := stringField(info, "devVer")
+	if version == "" {
+		c.Status, c.Detail = Skip, "firmware version unknown"
+		r.add(c)
+		return
+	}
+	r.report.Firmware = version
+	c.Status, c.Detail, c.Hint = checkFirmware(version)
+	r.add(c)
+}
+
+func (r *runner) address(info map[string]json.RawMessage) {
+	c := Check{Name: "address"}
+	reported := net.ParseIP(stringField(info, "ipAddr"))
+	if reported == nil {
+		c.Status, c.Detail = Skip, "device did not report an IP address"
+		r.add(c)
+		return
+	}
+	if slices.ContainsFunc(r.addrs, reported.Equal) {
+		c.Status, c.Detail = Pass, "device reports "+reported.String()
+		r.add(c)
+		return
+	}
+	c.Status = Warn
+	c.Detail = fmt.Sprintf("device reports %s, connected to %v", reported, r.addrs)
+	c.Hint = "Expected when connecting through a gateway or port forward. Otherwise the inverter's DHCP lease may change; reserve its address in the router."
+	r.add(c)
+}
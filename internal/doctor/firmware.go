@@ -0,0 +1,34 @@
+package doctor
+
+import (
+	"fmt"
+
+	"github.com/niclaszll/apsystems-ez1-tui/pkg/apsystems"
+)
+
+// quirk describes a known problem of firmware versions before fixedIn.
+type quirk struct {
+	fixedIn apsystems.FirmwareVersion
+	status  Status
+	detail  string
+	hint    string
+}
+
+// quirks lists known firmware problems, oldest first. Each entry needs
+// recorded responses in pkg/apsystems/testdata/firmware showing the problem;
+// none has been recorded so far.
+var quirks []quirk
+
+// checkFirmware looks up version in the quirk table.
+func checkFirmware(version string) (Status, string, string) {
+	v, ok := apsystems.ParseFirmware(version)
+	if !ok {
+		return Warn, fmt.Sprintf("unrecognized version %q", version), "Please attach the JSON report to a bug report so the version can be added."
+	}
+	for _, q := range quirks {
+		if v.Less(q.fixedIn) {
+			return q.status, fmt.Sprintf("%s: %s", version, q.detail), q.hint
+		}
+	}
+	return Pass, fmt.Sprintf("%s (profile %s)", version, apsystems.ProfileFor(version).Name), ""
+}
@@ -0,0 +1,59 @@
+package doctor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var symbols = map[Status]string{
+	Pass: "✓",
+	Warn: "!",
+	Fail: "✗",
+	Skip: "-",
+}
+
+// WriteText writes a human-readable report with a line per check and hints
+// below failed checks.
+func (r Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ez1-tui %s doctor, %s:%d\n\n", r.Version, r.Host, r.Port)
+	for _, c := range r.Checks {
+		line := fmt.Sprintf("%s %-20s %s", symbols[c.Status], c.Name, c.Detail)
+		if c.DurationMS > 0 && c.Status != Skip {
+			line += fmt.Sprintf(" (%.0f ms)", c.DurationMS)
+		}
+		b.WriteString(strings.TrimRight(line, " ") + "\n")
+		if c.Hint != "" {
+			fmt.Fprintf(&b, "  → %s\n", c.Hint)
+		}
+	}
+
+	var failed, warned int
+	for _, c := range r.Checks {
+		switch c.Status {
+		case Fail:
+			failed++
+		case Warn:
+			warned++
+		}
+	}
+	switch {
+	case failed > 0:
+		fmt.Fprintf(&b, "\n%d of %d checks failed, %d warnings. Run with -json to attach the report to a bug report.\n", failed, len(r.Checks), warned)
+	case warned > 0:
+		fmt.Fprintf(&b, "\nAll checks passed with %d warnings.\n", warned)
+	default:
+		b.WriteString("\nAll checks passed.\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
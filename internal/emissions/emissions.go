@@ -0,0 +1,163 @@
+package emissions
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/pkg/apsystems"
+)
+
+// Intensity reports the carbon intensity of the grid in grams of CO₂ per kWh.
+type Intensity interface {
+	At(t time.Time) float64
+	Average() float64
+}
+
+// Static is a constant grid intensity in g CO₂/kWh.
+type Static float64
+
+func (s Static) At(time.Time) float64 { return float64(s) }
+func (s Static) Average() float64     { return float64(s) }
+
+type profileEntry struct {
+	offset time.Duration // since local midnight
+	grams  float64
+}
+
+// Profile is a time-of-day intensity curve. Each entry applies from its start
+// time until the next entry, wrapping around midnight.
+type Profile struct {
+	entries []profileEntry
+}
+
+// LoadProfile reads a time-of-day profile from a file with one "HH:MM grams"
+// pair per line. Fields may be separated by whitespace or a comma; blank lines
+// and lines starting with # are ignored.
+func LoadProfile(path string) (*Profile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open profile: %w", err)
+	}
+	defer f.Close()
+
+	var p Profile
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("profile line %d: expected \"HH:MM grams\", got %q", lineNo, line)
+		}
+		clock, err := time.Parse("15:04", fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("profile line %d: invalid time %q", lineNo, fields[0])
+		}
+		grams, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || grams < 0 {
+			return nil, fmt.Errorf("profile line %d: invalid intensity %q", lineNo, fields[1])
+		}
+		p.entries = append(p.entries, profileEntry{
+			offset: time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute,
+			grams:  grams,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read profile: %w", err)
+	}
+	if len(p.entries) == 0 {
+		return nil, fmt.Errorf("profile %s contains no entries", path)
+	}
+
+	sort.Slice(p.entries, func(i, j int) bool { return p.entries[i].offset < p.entries[j].offset })
+	return &p, nil
+}
+
+func (p *Profile) At(t time.Time) float64 {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+
+	// Before the first entry the last one of the previous day still applies.
+	grams := p.entries[len(p.entries)-1].grams
+	for _, e := range p.entries {
+		if e.offset > offset {
+			break
+		}
+		grams = e.grams
+	}
+	return grams
+}
+
+// Average returns the time-weighted mean intensity over a full day.
+func (p *Profile) Average() float64 {
+	const day = 24 * time.Hour
+	var sum float64
+	for i, e := range p.entries {
+		end := day + p.entries[0].offset
+		if i+1 < len(p.entries) {
+			end = p.entries[i+1].offset
+		}
+		sum += e.grams * float64(end-e.offset)
+	}
+	return sum / float64(day)
+}
+
+// Avoided holds derived CO₂ figures in kilograms.
+type Avoided struct {
+	Today     float64
+	Lifetime  float64
+	Intensity float64 // g CO₂/kWh at the time of the last update
+}
+
+// Estimator converts produced energy into avoided CO₂. Energy produced between
+// two updates is weighted with the intensity at the time it was observed;
+// energy produced before the first observation (of the day, or of the device's
+// lifetime) is weighted with the daily average intensity.
+type Estimator struct {
+	intensity Intensity
+	day       string
+	lastToday float64
+	gramsDay  float64
+}
+
+func NewEstimator(intensity Intensity) *Estimator {
+	return &Estimator{intensity: intensity}
+}
+
+// Intensity returns the grid intensity the estimator was created with.
+func (e *Estimator) Intensity() Intensity {
+	return e.intensity
+}
+
+// Update folds a new statistics sample into the estimate.
+func (e *Estimator) Update(stats *apsystems.Statistics) Avoided {
+	now := stats.LastUpdate
+	day := now.Format(time.DateOnly)
+
+	switch {
+	case day != e.day || stats.TotalEnergyToday < e.lastToday:
+		// New day (or the device reset its counter): start from the average.
+		e.day = day
+		e.gramsDay = stats.TotalEnergyToday * e.intensity.Average()
+	default:
+		e.gramsDay += (stats.TotalEnergyToday - e.lastToday) * e.intensity.At(now)
+	}
+	e.lastToday = stats.TotalEnergyToday
+
+	return Avoided{
+		Today:     e.gramsDay / 1000,
+		Lifetime:  stats.TotalEnergyLifetime * e.intensity.Average() / 1000,
+		Intensity: e.intensity.At(now),
+	}
+}
@@ -0,0 +1,113 @@
+package gateway
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types published on the stream.
+const (
+	EventSample  = "sample"
+	EventAlarm   = "alarm"
+	EventControl = "control"
+)
+
+// Event is a single message on the live stream.
+type Event struct {
+	ID   uint64    `json:"id"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+// AlarmEvent reports an alarm being raised or cleared.
+type AlarmEvent struct {
+	Alarm  string `json:"alarm"`
+	Active bool   `json:"active"`
+}
+
+// ControlEvent reports a change of the power limit or power status, either
+// made through the gateway or observed on the device.
+type ControlEvent struct {
+	MaxPower *int   `json:"max_power_w,omitempty"`
+	Status   string `json:"status,omitempty"`
+}
+
+// subscriberBuffer is the number of events a subscriber may fall behind
+// before it is disconnected.
+const subscriberBuffer = 64
+
+// Subscription delivers events to one client. C is closed when the broker
+// drops a client that cannot keep up; it should reconnect and replay from
+// the last event it received.
+type Subscription struct {
+	C  <-chan Event
+	ch chan Event
+}
+
+// Broker fans events out to subscribers and keeps a bounded backlog for
+// replay.
+type Broker struct {
+	mu      sync.Mutex
+	nextID  uint64
+	backlog []Event
+	limit   int
+	subs    map[*Subscription]struct{}
+}
+
+func NewBroker(backlog int) *Broker {
+	return &Broker{nextID: 1, limit: backlog, subs: make(map[*Subscription]struct{})}
+}
+
+func (b *Broker) Publish(typ string, data any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e := Event{ID: b.nextID, Type: typ, Time: time.Now(), Data: data}
+	b.nextID++
+
+	b.backlog = append(b.backlog, e)
+	if len(b.backlog) > b.limit {
+		b.backlog = b.backlog[len(b.backlog)-b.limit:]
+	}
+
+	for sub := range b.subs {
+		select {
+		case sub.ch <- e:
+		default:
+			// Slow client: drop it rather than blocking everyone else.
+			close(sub.ch)
+			delete(b.subs, sub)
+		}
+	}
+}
+
+// Subscribe registers a subscriber and returns the backlog of events after
+// afterID or at/after since, whichever is set.
+func (b *Broker) Subscribe(afterID uint64, since time.Time) (*Subscription, []Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Event
+	if afterID > 0 || !since.IsZero() {
+		for _, e := range b.backlog {
+			if (afterID > 0 && e.ID > afterID) || (afterID == 0 && !e.Time.Before(since)) {
+				replay = append(replay, e)
+			}
+		}
+	}
+
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch}
+	b.subs[sub] = struct{}{}
+	return sub, replay
+}
+
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[sub]; ok {
+		close(sub.ch)
+		delete(b.subs, sub)
+	}
+}
@@ -0,0 +1,386 @@
+package gateway
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/pkg/apsystems"
+)
+
+type entry struct {
+	value any
+	at    time.Time
+}
+
+// call is an in-flight device request shared by all concurrent readers.
+type call struct {
+	done  chan struct{}
+	value any
+	err   error
+}
+
+// requestTimeout bounds a shared device request, which does not end with
+// the request of the reader that started it.
+const requestTimeout = 15 * time.Second
+
+// Poller is the single source of device traffic. It refreshes a cache in the
+// background, coalesces concurrent reads of the same endpoint into one device
+// request and sends one request at a time, reads and writes alike, because
+// the EZ1 cannot handle parallel load.
+type Poller struct {
+	client   *apsystems.Client
+	interval time.Duration
+	opts     []apsystems.WatcherOption
+
+	mu      sync.Mutex
+	cache   map[apsystems.Endpoint]entry
+	flights map[apsystems.Endpoint]*call
+
+	// deviceMu is held for every device request. It is taken before mu.
+	deviceMu sync.Mutex
+	events   *Broker
+}
+
+// NewPoller creates a poller refreshing output data every interval. Watcher
+// options such as apsystems.WithDaylight adjust the background polling.
+func NewPoller(client *apsystems.Client, interval time.Duration, opts ...apsystems.WatcherOption) *Poller {
+	return &Poller{
+		client:   client,
+		interval: interval,
+		opts:     opts,
+		cache:    make(map[apsystems.Endpoint]entry),
+		flights:  make(map[apsystems.Endpoint]*call),
+		events:   NewBroker(2000),
+	}
+}
+
+// Events returns the broker that receives a sample event for every output
+// reading, and alarm and control events whenever those values change.
+func (p *Poller) Events() *Broker {
+	return p.events
+}
+
+// Run polls until ctx is cancelled. Output data is refreshed every interval;
+// alarms, power status and limit every sixth interval and device info hourly.
+func (p *Poller) Run(ctx context.Context) {
+	opts := append([]apsystems.WatcherOption{
+		apsystems.WithInterval(apsystems.EndpointOutput, p.interval),
+		apsystems.WithInterval(apsystems.EndpointAlarm, 6*p.interval),
+		apsystems.WithInterval(apsystems.EndpointPowerStatus, 6*p.interval),
+		apsystems.WithInterval(apsystems.EndpointMaxPower, 6*p.interval),
+	}, p.opts...)
+	apsystems.NewWatcher(refresher{p}, opts...).Run(ctx)
+}
+
+// refresher lets a Watcher drive the poller: every read bypasses the cache
+// but still shares in-flight requests and updates the cache.
+type refresher struct {
+	p *Poller
+}
+
+func fetchAs[T any](ctx context.Context, p *Poller, ep apsystems.Endpoint) (*T, error) {
+	v, err := p.fetch(ctx, ep)
+	if err != nil {
+		return nil, err
+	}
+	return v.(*T), nil
+}
+
+func (r refresher) GetOutputData(ctx context.Context) (*apsystems.OutputData, error) {
+	return fetchAs[apsystems.OutputData](ctx, r.p, apsystems.EndpointOutput)
+}
+
+func (r refresher) GetDeviceInfo(ctx context.Context) (*apsystems.DeviceInfo, error) {
+	return fetchAs[apsystems.DeviceInfo](ctx, r.p, apsystems.EndpointDeviceInfo)
+}
+
+func (r refresher) GetAlarmInfo(ctx context.Context) (*apsystems.AlarmInfo, error) {
+	return fetchAs[apsystems.AlarmInfo](ctx, r.p, apsystems.EndpointAlarm)
+}
+
+func (r refresher) GetDevicePowerStatus(ctx context.Context) (*apsystems.PowerStatus, error) {
+	return fetchAs[apsystems.PowerStatus](ctx, r.p, apsystems.EndpointPowerStatus)
+}
+
+func (r refresher) GetMaxPower(ctx context.Context) (*apsystems.PowerLimit, error) {
+	return fetchAs[apsystems.PowerLimit](ctx, r.p, apsystems.EndpointMaxPower)
+}
+
+// get returns the cached value if it is younger than maxAge, otherwise fetches
+// it. If the device cannot be reached a stale value is returned together with
+// its age; an error is returned only when nothing is cached at all.
+func (p *Poller) get(ctx context.Context, ep apsystems.Endpoint, maxAge time.Duration) (any, time.Time, error) {
+	p.mu.Lock()
+	cached, ok := p.cache[ep]
+	p.mu.Unlock()
+	if ok && time.Since(cached.at) < maxAge {
+		return cached.value, cached.at, nil
+	}
+
+	value, err := p.fetch(ctx, ep)
+	if err != nil {
+		if ok {
+			return cached.value, cached.at, nil
+		}
+		return nil, time.Time{}, err
+	}
+	return value, time.Now(), nil
+}
+
+// fetch returns the result of the in-flight request for ep, starting one if
+// there is none. A cancelled ctx only stops waiting; the request completes
+// for the other readers.
+func (p *Poller) fetch(ctx context.Context, ep apsystems.Endpoint) (any, error) {
+	p.mu.Lock()
+	c, ok := p.flights[ep]
+	if !ok {
+		c = p.start(ctx, ep)
+	}
+	p.mu.Unlock()
+	return wait(ctx, c)
+}
+
+// refetch starts a new request for ep even if one is in flight, so that the
+// result reflects a write made after that request was started.
+func (p *Poller) refetch(ctx context.Context, ep apsystems.Endpoint) (any, error) {
+	p.mu.Lock()
+	c := p.start(ctx, ep)
+	p.mu.Unlock()
+	return wait(ctx, c)
+}
+
+func wait(ctx context.Context, c *call) (any, error) {
+	select {
+	case <-c.done:
+		return c.value, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// start registers a new call for ep and runs it in the background on a
+// context detached from ctx. p.mu must be held.
+func (p *Poller) start(ctx context.Context, ep apsystems.Endpoint) *call {
+	c := &call{done: make(chan struct{})}
+	p.flights[ep] = c
+	go p.run(context.WithoutCancel(ctx), ep, c)
+	return c
+}
+
+func (p *Poller) run(ctx context.Context, ep apsystems.Endpoint, c *call) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	// The cache is updated and events are published before the device is
+	// released, so that a read finishing before a write cannot overwrite the
+	// value read after it.
+	p.deviceMu.Lock()
+	defer close(c.done)
+	defer p.deviceMu.Unlock()
+
+	c.value, c.err = p.request(ctx, ep)
+	p.mu.Lock()
+	if p.flights[ep] == c {
+		delete(p.flights, ep)
+	}
+	prev, hadPrev := p.cache[ep]
+	if c.err == nil {
+		p.cache[ep] = entry{value: c.value, at: time.Now()}
+	}
+	p.mu.Unlock()
+
+	if c.err == nil {
+		var old any
+		if hadPrev {
+			old = prev.value
+		}
+		p.publish(ep, old, c.value)
+	}
+}
+
+func (p *Poller) request(ctx context.Context, ep apsystems.Endpoint) (any, error) {
+	switch ep {
+	case apsystems.EndpointOutput:
+		return p.client.GetOutputData(ctx)
+	case apsystems.EndpointDeviceInfo:
+		return p.client.GetDeviceInfo(ctx)
+	case apsystems.EndpointAlarm:
+		return p.client.GetAlarmInfo(ctx)
+	case apsystems.EndpointPowerStatus:
+		return p.client.GetDevicePowerStatus(ctx)
+	default:
+		return p.client.GetMaxPower(ctx)
+	}
+}
+
+func (p *Poller) maxAge(ep apsystems.Endpoint) time.Duration {
+	if ep == apsystems.EndpointOutput {
+		return 2 * p.interval
+	}
+	return 12 * p.interval
+}
+
+func (p *Poller) OutputData(ctx context.Context) (*apsystems.OutputData, time.Time, error) {
+	v, at, err := p.get(ctx, apsystems.EndpointOutput, p.maxAge(apsystems.EndpointOutput))
+	if err != nil {
+		return nil, at, err
+	}
+	return v.(*apsystems.OutputData), at, nil
+}
+
+func (p *Poller) DeviceInfo(ctx context.Context) (*apsystems.DeviceInfo, time.Time, error) {
+	v, at, err := p.get(ctx, apsystems.EndpointDeviceInfo, time.Hour)
+	if err != nil {
+		return nil, at, err
+	}
+	return v.(*apsystems.DeviceInfo), at, nil
+}
+
+func (p *Poller) AlarmInfo(ctx context.Context) (*apsystems.AlarmInfo, time.Time, error) {
+	v, at, err := p.get(ctx, apsystems.EndpointAlarm, p.maxAge(apsystems.EndpointAlarm))
+	if err != nil {
+		return nil, at, err
+	}
+	return v.(*apsystems.AlarmInfo), at, nil
+}
+
+func (p *Poller) PowerStatus(ctx context.Context) (*apsystems.PowerStatus, time.Time, error) {
+	v, at, err := p.get(ctx, apsystems.EndpointPowerStatus, p.maxAge(apsystems.EndpointPowerStatus))
+	if err != nil {
+		return nil, at, err
+	}
+	return v.(*apsystems.PowerStatus), at, nil
+}
+
+func (p *Poller) PowerLimit(ctx context.Context) (*apsystems.PowerLimit, time.Time, error) {
+	v, at, err := p.get(ctx, apsystems.EndpointMaxPower, p.maxAge(apsystems.EndpointMaxPower))
+	if err != nil {
+		return nil, at, err
+	}
+	return v.(*apsystems.PowerLimit), at, nil
+}
+
+// SetMaxPower changes the power limit and refreshes the cached value.
+func (p *Poller) SetMaxPower(ctx context.Context, watts int) error {
+	p.deviceMu.Lock()
+	err := p.client.SetMaxPower(ctx, watts)
+	p.deviceMu.Unlock()
+	if err != nil {
+		return err
+	}
+	p.refetch(ctx, apsystems.EndpointMaxPower)
+	return nil
+}
+
+// SetDevicePowerStatus switches the device on or off and refreshes the cached
+// status.
+func (p *Poller) SetDevicePowerStatus(ctx context.Context, status string) error {
+	p.deviceMu.Lock()
+	err := p.client.SetDevicePowerStatus(ctx, status)
+	p.deviceMu.Unlock()
+	if err != nil {
+		return err
+	}
+	p.refetch(ctx, apsystems.EndpointPowerStatus)
+	return nil
+}
+
+func (p *Poller) publish(ep apsystems.Endpoint, old, cur any) {
+	switch ep {
+	case apsystems.EndpointOutput:
+		p.events.Publish(EventSample, newStatusResponse(cur.(*apsystems.OutputData), time.Now()))
+
+	case apsystems.EndpointAlarm:
+		var prev AlarmsResponse
+		if old != nil {
+			prev = newAlarmsResponse(old.(*apsystems.AlarmInfo))
+		}
+		next := newAlarmsResponse(cur.(*apsystems.AlarmInfo))
+		for _, a := range []struct {
+			name       string
+			prev, next *bool
+		}{
+			{"grid_fault", prev.GridFault, next.GridFault},
+			{"pv1_short_circuit", prev.PV1ShortCircuit, next.PV1ShortCircuit},
+			{"pv2_short_circuit", prev.PV2ShortCircuit, next.PV2ShortCircuit},
+			{"output_error", prev.OutputError, next.OutputError},
+		} {
+			// A flag that is not reported neither raises nor clears the
+			// alarm; one that was not reported before counts as cleared.
+			if a.next == nil || (a.prev != nil && *a.prev == *a.next) || (a.prev == nil && !*a.next) {
+				continue
+			}
+			p.events.Publish(EventAlarm, AlarmEvent{Alarm: a.name, Active: *a.next})
+		}
+
+	case apsystems.EndpointPowerStatus:
+		next := cur.(*apsystems.PowerStatus).Data.Status.String()
+		if old == nil || old.(*apsystems.PowerStatus).Data.Status.String() != next {
+			p.events.Publish(EventControl, ControlEvent{Status: next})
+		}
+
+	case apsystems.EndpointMaxPower:
+		next := int(cur.(*apsystems.PowerLimit).Data.MaxPower)
+		if old == nil || int(old.(*apsystems.PowerLimit).Data.MaxPower) != next {
+			p.events.Publish(EventControl, ControlEvent{MaxPower: &next})
+		}
+	}
+}
+
+// Cached adapts the poller to the method set of apsystems.Client, so that
+// in-process consumers such as the TUI read from the cache instead of
+// talking to the device.
+type Cached struct {
+	p *Poller
+}
+
+func (p *Poller) Cached() Cached {
+	return Cached{p: p}
+}
+
+func (c Cached) GetOutputData(ctx context.Context) (*apsystems.OutputData, error) {
+	v, _, err := c.p.OutputData(ctx)
+	return v, err
+}
+
+func (c Cached) GetStatistics(ctx context.Context) (*apsystems.Statistics, error) {
+	out, at, err := c.p.OutputData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return apsystems.NewStatistics(out, at), nil
+}
+
+func (c Cached) GetDeviceInfo(ctx context.Context) (*apsystems.DeviceInfo, error) {
+	v, _, err := c.p.DeviceInfo(ctx)
+	return v, err
+}
+
+func (c Cached) GetAlarmInfo(ctx context.Context) (*apsystems.AlarmInfo, error) {
+	v, _, err := c.p.AlarmInfo(ctx)
+	return v, err
+}
+
+func (c Cached) GetDevicePowerStatus(ctx context.Context) (*apsystems.PowerStatus, error) {
+	v, _, err := c.p.PowerStatus(ctx)
+	return v, err
+}
+
+func (c Cached) GetMaxPower(ctx context.Context) (*apsystems.PowerLimit, error) {
+	v, _, err := c.p.PowerLimit(ctx)
+	return v, err
+}
+
+func (c Cached) SetMaxPower(ctx context.Context, watts int) error {
+	return c.p.SetMaxPower(ctx, watts)
+}
+
+func (c Cached) SetDevicePowerStatus(ctx context.Context, status string) error {
+	return c.p.SetDevicePowerStatus(ctx, status)
+}
+
+// Health reports the connection statistics of the underlying client.
+func (c Cached) Health() apsystems.Health {
+	return c.p.client.Health()
+}
@@ -0,0 +1,338 @@
+package gateway
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/pkg/apsystems"
+)
+
+//go:embed openapi.json
+var openAPI []byte
+
+// Access is the permission granted by a token.
+type Access int
+
+const (
+	AccessRead Access = iota + 1
+	AccessWrite
+)
+
+// Server exposes the poller as a versioned REST API. It also serves the EZ1's
+// native endpoints so that apsystems.Client can use the gateway in place of
+// the device.
+type Server struct {
+	poller *Poller
+	tokens map[string]Access
+	mux    *http.ServeMux
+}
+
+// NewServer creates the HTTP handler. Without tokens the API is open.
+func NewServer(poller *Poller, tokens map[string]Access) *Server {
+	s := &Server{poller: poller, tokens: tokens, mux: http.NewServeMux()}
+
+	s.mux.HandleFunc("GET /v1/openapi.json", s.handleOpenAPI)
+	s.mux.HandleFunc("GET /v1/status", s.read(s.handleStatus))
+	s.mux.HandleFunc("GET /v1/device", s.read(s.handleDevice))
+	s.mux.HandleFunc("GET /v1/alarms", s.read(s.handleAlarms))
+	s.mux.HandleFunc("GET /v1/limit", s.read(s.handleGetLimit))
+	s.mux.HandleFunc("PUT /v1/limit", s.write(s.handlePutLimit))
+	s.mux.HandleFunc("GET /v1/power", s.read(s.handleGetPower))
+	s.mux.HandleFunc("PUT /v1/power", s.write(s.handlePutPower))
+	s.mux.HandleFunc("GET /v1/events", s.read(s.handleSSE))
+	s.mux.HandleFunc("GET /v1/ws", s.read(s.handleWebSocket))
+
+	s.mux.HandleFunc("GET /getOutputData", s.read(s.native(func(ctx context.Context) (any, time.Time, error) { return s.poller.OutputData(ctx) })))
+	s.mux.HandleFunc("GET /getDeviceInfo", s.read(s.native(func(ctx context.Context) (any, time.Time, error) { return s.poller.DeviceInfo(ctx) })))
+	s.mux.HandleFunc("GET /getAlarm", s.read(s.native(func(ctx context.Context) (any, time.Time, error) { return s.poller.AlarmInfo(ctx) })))
+	s.mux.HandleFunc("GET /getOnOff", s.read(s.native(func(ctx context.Context) (any, time.Time, error) { return s.poller.PowerStatus(ctx) })))
+	s.mux.HandleFunc("GET /getMaxPower", s.read(s.native(func(ctx context.Context) (any, time.Time, error) { return s.poller.PowerLimit(ctx) })))
+	s.mux.HandleFunc("GET /setMaxPower", s.write(s.handleNativeSetMaxPower))
+	s.mux.HandleFunc("GET /setOnOff", s.write(s.handleNativeSetOnOff))
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// HandlePublic registers an additional handler that is served without
+// authentication, e.g. the static files of the web UI.
+func (s *Server) HandlePublic(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+func (s *Server) authorize(r *http.Request, need Access) (int, bool) {
+	if len(s.tokens) == 0 {
+		return 0, true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.URL.Query().Get("token")
+	}
+	if token == "" {
+		return http.StatusUnauthorized, false
+	}
+	for t, access := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			if access < need {
+				return http.StatusForbidden, false
+			}
+			return 0, true
+		}
+	}
+	return http.StatusUnauthorized, false
+}
+
+func (s *Server) guard(need Access, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if code, ok := s.authorize(r, need); !ok {
+			if code == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="ez1"`)
+			}
+			writeError(w, code, http.StatusText(code))
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (s *Server) read(h http.HandlerFunc) http.HandlerFunc  { return s.guard(AccessRead, h) }
+func (s *Server) write(h http.HandlerFunc) http.HandlerFunc { return s.guard(AccessWrite, h) }
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, errorResponse{Error: msg})
+}
+
+// writeData writes a cached value with its age so clients can detect stale data.
+func writeData(w http.ResponseWriter, v any, at time.Time) {
+	w.Header().Set("Last-Modified", at.UTC().Format(http.TimeFormat))
+	w.Header().Set("X-Data-Age", strconv.Itoa(int(time.Since(at).Seconds())))
+	writeJSON(w, http.StatusOK, v)
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPI)
+}
+
+// StatusResponse is returned by GET /v1/status.
+type StatusResponse struct {
+	Power          ChannelValues `json:"power_w"`
+	EnergyToday    ChannelValues `json:"energy_today_kwh"`
+	EnergyLifetime ChannelValues `json:"energy_lifetime_kwh"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+type ChannelValues struct {
+	PV1   float64 `json:"pv1"`
+	PV2   float64 `json:"pv2"`
+	Total float64 `json:"total"`
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	out, at, err := s.poller.OutputData(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeData(w, newStatusResponse(out, at), at)
+}
+
+func newStatusResponse(out *apsystems.OutputData, at time.Time) StatusResponse {
+	d := out.Data
+	return StatusResponse{
+		Power:          ChannelValues{PV1: float64(d.P1), PV2: float64(d.P2), Total: float64(d.P1 + d.P2)},
+		EnergyToday:    ChannelValues{PV1: float64(d.E1), PV2: float64(d.E2), Total: float64(d.E1 + d.E2)},
+		EnergyLifetime: ChannelValues{PV1: float64(d.Te1), PV2: float64(d.Te2), Total: float64(d.Te1 + d.Te2)},
+		UpdatedAt:      at,
+	}
+}
+
+// DeviceResponse is returned by GET /v1/device.
+type DeviceResponse struct {
+	DeviceID string `json:"device_id"`
+	Firmware string `json:"firmware"`
+	IPAddr   string `json:"ip_addr"`
+	SSID     string `json:"ssid"`
+	MinPower int    `json:"min_power_w"`
+	MaxPower int    `json:"max_power_w"`
+}
+
+func (s *Server) handleDevice(w http.ResponseWriter, r *http.Request) {
+	info, at, err := s.poller.DeviceInfo(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	d := info.Data
+	writeData(w, DeviceResponse{
+		DeviceID: d.DeviceID,
+		Firmware: d.Firmware,
+		IPAddr:   d.IPAddr,
+		SSID:     d.SSIDName,
+		MinPower: int(d.MinPower),
+		MaxPower: int(d.MaxPower),
+	}, at)
+}
+
+// AlarmsResponse is returned by GET /v1/alarms. A flag is nil while the
+// device does not report it.
+type AlarmsResponse struct {
+	GridFault       *bool `json:"grid_fault"`
+	PV1ShortCircuit *bool `json:"pv1_short_circuit"`
+	PV2ShortCircuit *bool `json:"pv2_short_circuit"`
+	OutputError     *bool `json:"output_error"`
+}
+
+func (s *Server) handleAlarms(w http.ResponseWriter, r *http.Request) {
+	alarm, at, err := s.poller.AlarmInfo(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeData(w, newAlarmsResponse(alarm), at)
+}
+
+func newAlarmsResponse(alarm *apsystems.AlarmInfo) AlarmsResponse {
+	d := alarm.Data
+	return AlarmsResponse{
+		GridFault:       alarmFlag(d.Og),
+		PV1ShortCircuit: alarmFlag(d.Isce1),
+		PV2ShortCircuit: alarmFlag(d.Isce2),
+		OutputError:     alarmFlag(d.Oe),
+	}
+}
+
+func alarmFlag(s apsystems.AlarmState) *bool {
+	if !s.Known() {
+		return nil
+	}
+	active := s.Active()
+	return &active
+}
+
+// LimitBody is the request and response body of /v1/limit.
+type LimitBody struct {
+	MaxPower int `json:"max_power_w"`
+}
+
+func (s *Server) handleGetLimit(w http.ResponseWriter, r *http.Request) {
+	limit, at, err := s.poller.PowerLimit(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeData(w, LimitBody{MaxPower: int(limit.Data.MaxPower)}, at)
+}
+
+func (s *Server) handlePutLimit(w http.ResponseWriter, r *http.Request) {
+	var body LimitBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid body: %v", err))
+		return
+	}
+	if err := s.poller.SetMaxPower(r.Context(), body.MaxPower); err != nil {
+		writeWriteError(w, err)
+		return
+	}
+	s.handleGetLimit(w, r)
+}
+
+// PowerBody is the request and response body of /v1/power.
+type PowerBody struct {
+	Status string `json:"status"` // "ON" or "OFF", "UNKNOWN" if not reported
+}
+
+func (s *Server) handleGetPower(w http.ResponseWriter, r *http.Request) {
+	status, at, err := s.poller.PowerStatus(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeData(w, PowerBody{Status: status.Data.Status.String()}, at)
+}
+
+func (s *Server) handlePutPower(w http.ResponseWriter, r *http.Request) {
+	var body PowerBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid body: %v", err))
+		return
+	}
+	if err := s.poller.SetDevicePowerStatus(r.Context(), strings.ToUpper(body.Status)); err != nil {
+		writeWriteError(w, err)
+		return
+	}
+	s.handleGetPower(w, r)
+}
+
+// writeWriteError distinguishes rejected input from device failures.
+func writeWriteError(w http.ResponseWriter, err error) {
+	if errors.Is(err, apsystems.ErrInvalidArgument) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeError(w, http.StatusBadGateway, err.Error())
+}
+
+// native serves a cached value in the device's own response format.
+func (s *Server) native(get func(context.Context) (any, time.Time, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		v, at, err := get(r.Context())
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		writeData(w, v, at)
+	}
+}
+
+func (s *Server) handleNativeSetMaxPower(w http.ResponseWriter, r *http.Request) {
+	watts, err := strconv.Atoi(r.URL.Query().Get("p"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid power")
+		return
+	}
+	if err := s.poller.SetMaxPower(r.Context(), watts); err != nil {
+		writeWriteError(w, err)
+		return
+	}
+	s.native(func(ctx context.Context) (any, time.Time, error) { return s.poller.PowerLimit(ctx) })(w, r)
+}
+
+func (s *Server) handleNativeSetOnOff(w http.ResponseWriter, r *http.Request) {
+	var status string
+	switch r.URL.Query().Get("status") {
+	case "0":
+		status = "ON"
+	case "1":
+		status = "OFF"
+	default:
+		writeError(w, http.StatusBadRequest, "invalid status")
+		return
+	}
+	if err := s.poller.SetDevicePowerStatus(r.Context(), status); err != nil {
+		writeWriteError(w, err)
+		return
+	}
+	s.native(func(ctx context.Context) (any, time.Time, error) { return s.poller.PowerStatus(ctx) })(w, r)
+}
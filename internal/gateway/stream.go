@@ -0,0 +1,168 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	heartbeatInterval = 30 * time.Second
+	writeTimeout      = 10 * time.Second
+)
+
+// replayParams reads the replay position from the Last-Event-ID header or the
+// last_id and since query parameters.
+func replayParams(r *http.Request) (uint64, time.Time, error) {
+	lastID := r.Header.Get("Last-Event-ID")
+	if q := r.URL.Query().Get("last_id"); q != "" {
+		lastID = q
+	}
+	var afterID uint64
+	if lastID != "" {
+		id, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			return 0, time.Time{}, fmt.Errorf("invalid last event ID %q", lastID)
+		}
+		afterID = id
+	}
+
+	var since time.Time
+	if q := r.URL.Query().Get("since"); q != "" {
+		t, err := time.Parse(time.RFC3339, q)
+		if err != nil {
+			return 0, time.Time{}, fmt.Errorf("invalid since %q (must be RFC 3339)", q)
+		}
+		since = t
+	}
+	return afterID, since, nil
+}
+
+// handleSSE streams events as Server-Sent Events.
+func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
+	afterID, since, err := replayParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rc := http.NewResponseController(w)
+
+	sub, replay := s.poller.Events().Subscribe(afterID, since)
+	defer s.poller.Events().Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(e Event) error {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	for _, e := range replay {
+		if err := send(e); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client reconnects with Last-Event-ID.
+				fmt.Fprint(w, "event: overflow\ndata: {}\n\n")
+				rc.Flush()
+				return
+			}
+			if err := send(e); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleWebSocket streams events as JSON text messages over a WebSocket.
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	afterID, since, err := replayParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer ws.Close()
+
+	sub, replay := s.poller.Events().Subscribe(afterID, since)
+	defer s.poller.Events().Unsubscribe(sub)
+
+	closed := make(chan struct{})
+	go func() {
+		ws.readLoop()
+		close(closed)
+	}()
+
+	send := func(e Event) error {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		return ws.WriteText(data, writeTimeout)
+	}
+
+	for _, e := range replay {
+		if err := send(e); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				ws.WriteClose(closePolicyViolation, "overflow", writeTimeout)
+				return
+			}
+			if err := send(e); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := ws.WritePing(writeTimeout); err != nil {
+				return
+			}
+		}
+	}
+}
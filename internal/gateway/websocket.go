@@ -0,0 +1,189 @@
+package gateway
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A minimal server side RFC 6455 implementation, enough to push text messages
+// and answer pings and close frames.
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+
+	closePolicyViolation = 1008
+
+	maxControlPayload = 125
+)
+
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	mu   sync.Mutex // serializes writes
+}
+
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return nil, errors.New("not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, errors.New("unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, errors.New("missing Sec-WebSocket-Key")
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return &wsConn{conn: conn, rw: rw}, nil
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *wsConn) writeFrame(op byte, payload []byte, timeout time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	header := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(n))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(timeout))
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+func (c *wsConn) WriteText(data []byte, timeout time.Duration) error {
+	return c.writeFrame(opText, data, timeout)
+}
+
+func (c *wsConn) WritePing(timeout time.Duration) error {
+	return c.writeFrame(opPing, nil, timeout)
+}
+
+func (c *wsConn) WriteClose(code uint16, reason string, timeout time.Duration) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, code)
+	payload = append(payload, reason...)
+	if len(payload) > maxControlPayload {
+		payload = payload[:maxControlPayload]
+	}
+	return c.writeFrame(opClose, payload, timeout)
+}
+
+// readLoop consumes client frames until the connection closes. Clients only
+// send control frames on this stream; data frames are discarded.
+func (c *wsConn) readLoop() {
+	for {
+		op, payload, err := c.readFrame()
+		if err != nil {
+			return
+		}
+		switch op {
+		case opClose:
+			c.writeFrame(opClose, payload, writeTimeout)
+			return
+		case opPing:
+			c.writeFrame(opPong, payload, writeTimeout)
+		}
+	}
+}
+
+func (c *wsConn) readFrame() (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.rw, head[:]); err != nil {
+		return 0, nil, err
+	}
+	op := head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7F)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if !masked {
+		return 0, nil, errors.New("client frame not masked")
+	}
+	if length > 1<<16 {
+		return 0, nil, errors.New("frame too large")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return op, payload, nil
+}
@@ -0,0 +1,287 @@
+package history
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is an interchange format for exported samples.
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatJSONL Format = "jsonl"
+	FormatTSV   Format = "tsv"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(s)) {
+	case FormatCSV:
+		return FormatCSV, nil
+	case FormatJSONL, "json":
+		return FormatJSONL, nil
+	case FormatTSV, "parquet-free-tsv":
+		return FormatTSV, nil
+	}
+	return "", fmt.Errorf("unknown format %q (must be csv, jsonl or tsv)", s)
+}
+
+var columns = []string{"timestamp", "p1", "p2", "e1", "e2", "te1", "te2", "limit", "status", "alarms"}
+
+// Column is an additional, derived column appended to exports. Its value is
+// written with the decimal separator of the format. Derived columns are
+// ignored on import.
+type Column struct {
+	Name  string
+	Value func(Sample) float64
+}
+
+// Writer encodes samples in one of the interchange formats.
+type Writer struct {
+	format  Format
+	extra   []Column
+	csv     *csv.Writer
+	json    *json.Encoder
+	decimal string
+	started bool
+}
+
+func NewWriter(w io.Writer, format Format, extra ...Column) *Writer {
+	wr := &Writer{format: format, extra: extra, decimal: "."}
+	switch format {
+	case FormatJSONL:
+		wr.json = json.NewEncoder(w)
+	default:
+		wr.csv = csv.NewWriter(w)
+		if format == FormatTSV {
+			wr.csv.Comma = '\t'
+		}
+	}
+	return wr
+}
+
+// SetDecimal sets the decimal separator of the delimited formats for
+// spreadsheets in locales that use a decimal comma. CSV then separates
+// fields with semicolons, which Read detects.
+func (w *Writer) SetDecimal(sep string) {
+	if w.csv == nil {
+		return
+	}
+	w.decimal = sep
+	if sep == "," && w.format == FormatCSV {
+		w.csv.Comma = ';'
+	}
+}
+
+func (w *Writer) Write(s Sample) error {
+	if w.json != nil {
+		if len(w.extra) == 0 {
+			return w.json.Encode(s)
+		}
+		// Add derived columns next to the sample fields.
+		record := map[string]any{}
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &record); err != nil {
+			return err
+		}
+		for _, col := range w.extra {
+			record[col.Name] = col.Value(s)
+		}
+		return w.json.Encode(record)
+	}
+
+	if !w.started {
+		header := append([]string{}, columns...)
+		for _, col := range w.extra {
+			header = append(header, col.Name)
+		}
+		if err := w.csv.Write(header); err != nil {
+			return err
+		}
+		w.started = true
+	}
+
+	record := []string{
+		s.Time.Format(time.RFC3339Nano),
+		strconv.Itoa(s.P1),
+		strconv.Itoa(s.P2),
+		w.formatFloat(s.E1),
+		w.formatFloat(s.E2),
+		w.formatFloat(s.Te1),
+		w.formatFloat(s.Te2),
+		strconv.Itoa(s.Limit),
+		strconv.Itoa(s.Status),
+		strconv.Itoa(int(s.Alarms)),
+	}
+	for _, col := range w.extra {
+		record = append(record, w.formatFloat(col.Value(s)))
+	}
+	return w.csv.Write(record)
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *Writer) Flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+	return nil
+}
+
+func (w *Writer) formatFloat(f float64) string {
+	return strings.Replace(strconv.FormatFloat(f, 'f', -1, 64), ".", w.decimal, 1)
+}
+
+// Read decodes all samples from r.
+func Read(r io.Reader, format Format) ([]Sample, error) {
+	if format == FormatJSONL {
+		return readJSONL(r)
+	}
+	return readDelimited(r, format)
+}
+
+func readJSONL(r io.Reader) ([]Sample, error) {
+	var samples []Sample
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var s Sample
+		if err := json.Unmarshal([]byte(line), &s); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		samples = append(samples, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+func readDelimited(r io.Reader, format Format) ([]Sample, error) {
+	// CSV from spreadsheets with a decimal comma uses semicolons; in TSV
+	// a comma can only be a decimal separator.
+	br := bufio.NewReader(r)
+	first, err := br.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	cr := csv.NewReader(io.MultiReader(strings.NewReader(first), br))
+	decimalComma := false
+	switch {
+	case format == FormatTSV:
+		cr.Comma = '\t'
+		decimalComma = true
+	case strings.Contains(first, ";"):
+		cr.Comma = ';'
+		decimalComma = true
+	}
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range columns {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+
+	var samples []Sample
+	for lineNo := 2; ; lineNo++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		p := fieldParser{record: record, index: index, decimalComma: decimalComma}
+		s := Sample{
+			Time:   p.time("timestamp"),
+			P1:     p.int("p1"),
+			P2:     p.int("p2"),
+			E1:     p.float("e1"),
+			E2:     p.float("e2"),
+			Te1:    p.float("te1"),
+			Te2:    p.float("te2"),
+			Limit:  p.int("limit"),
+			Status: p.int("status"),
+			Alarms: uint8(p.int("alarms")),
+		}
+		if p.err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, p.err)
+		}
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
+
+// fieldParser extracts typed fields from a record, remembering the first error.
+type fieldParser struct {
+	record []string
+	index  map[string]int
+	// decimalComma accepts floats like 1,5.
+	decimalComma bool
+	err          error
+}
+
+func (p *fieldParser) field(name string) string {
+	i := p.index[name]
+	if i >= len(p.record) {
+		if p.err == nil {
+			p.err = fmt.Errorf("missing value for %q", name)
+		}
+		return ""
+	}
+	return strings.TrimSpace(p.record[i])
+}
+
+func (p *fieldParser) time(name string) time.Time {
+	v := p.field(name)
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("invalid %s %q", name, v)
+	}
+	return t
+}
+
+func (p *fieldParser) int(name string) int {
+	v := p.field(name)
+	i, err := strconv.Atoi(v)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("invalid %s %q", name, v)
+	}
+	return i
+}
+
+func (p *fieldParser) float(name string) float64 {
+	v := p.field(name)
+	if p.decimalComma {
+		v = strings.Replace(v, ",", ".", 1)
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("invalid %s %q", name, v)
+	}
+	return f
+}
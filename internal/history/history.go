@@ -0,0 +1,316 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/pkg/apsystems"
+)
+
+// Alarm bits stored in Sample.Alarms.
+const (
+	AlarmGridFault uint8 = 1 << iota
+	AlarmPV1ShortCircuit
+	AlarmPV2ShortCircuit
+	AlarmOutputError
+	// AlarmUnknown is set if any flag was not reported.
+	AlarmUnknown uint8 = 1 << 7
+)
+
+// Sample is a single recorded reading of the inverter.
+type Sample struct {
+	Time   time.Time `json:"time"`
+	P1     int       `json:"p1"`
+	P2     int       `json:"p2"`
+	E1     float64   `json:"e1"`
+	E2     float64   `json:"e2"`
+	Te1    float64   `json:"te1"`
+	Te2    float64   `json:"te2"`
+	Limit  int       `json:"limit"`
+	Status int       `json:"status"` // apsystems.PowerState, -1 if unknown
+	Alarms uint8     `json:"alarms"`
+}
+
+// NewSample builds a sample from the latest readings. Any of limit, status and
+// alarm may be nil if they have not been fetched yet; the status and alarms
+// are then recorded as unknown.
+func NewSample(stats *apsystems.Statistics, limit *apsystems.PowerLimit, status *apsystems.PowerStatus, alarm *apsystems.AlarmInfo) Sample {
+	s := Sample{
+		Time:   stats.LastUpdate,
+		P1:     stats.Power1,
+		P2:     stats.Power2,
+		E1:     stats.EnergyToday1,
+		E2:     stats.EnergyToday2,
+		Te1:    stats.EnergyLifetime1,
+		Te2:    stats.EnergyLifetime2,
+		Status: int(apsystems.PowerUnknown),
+		Alarms: AlarmUnknown,
+	}
+	if limit != nil {
+		s.Limit = int(limit.Data.MaxPower)
+	}
+	if status != nil {
+		s.Status = int(status.Data.Status)
+	}
+	if alarm != nil {
+		s.Alarms = AlarmBits(alarm)
+	}
+	return s
+}
+
+// AlarmBits packs the alarm flags into a bit set.
+func AlarmBits(alarm *apsystems.AlarmInfo) uint8 {
+	var bits uint8
+	if alarm.Data.Og.Active() {
+		bits |= AlarmGridFault
+	}
+	if alarm.Data.Isce1.Active() {
+		bits |= AlarmPV1ShortCircuit
+	}
+	if alarm.Data.Isce2.Active() {
+		bits |= AlarmPV2ShortCircuit
+	}
+	if alarm.Data.Oe.Active() {
+		bits |= AlarmOutputError
+	}
+	for _, state := range []apsystems.AlarmState{alarm.Data.Og, alarm.Data.Isce1, alarm.Data.Isce2, alarm.Data.Oe} {
+		if !state.Known() {
+			bits |= AlarmUnknown
+		}
+	}
+	return bits
+}
+
+// Statistics converts the sample back into aggregated statistics.
+func (s Sample) Statistics() *apsystems.Statistics {
+	return &apsystems.Statistics{
+		Power1:              s.P1,
+		EnergyToday1:        s.E1,
+		EnergyLifetime1:     s.Te1,
+		Power2:              s.P2,
+		EnergyToday2:        s.E2,
+		EnergyLifetime2:     s.Te2,
+		TotalPower:          s.P1 + s.P2,
+		TotalEnergyToday:    s.E1 + s.E2,
+		TotalEnergyLifetime: s.Te1 + s.Te2,
+		LastUpdate:          s.Time,
+	}
+}
+
+// DefaultDir returns the default location of the history store.
+func DefaultDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "ez1-tui", "history")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "ez1-tui", "history")
+	}
+	return filepath.Join(home, ".local", "share", "ez1-tui", "history")
+}
+
+// Store keeps samples as JSON Lines, one file per local day.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create history dir: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) dayFile(day string) string {
+	return filepath.Join(s.dir, day+".jsonl")
+}
+
+// Append adds samples to the end of their day files.
+func (s *Store) Append(samples ...Sample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for day, group := range groupByDay(samples) {
+		f, err := os.OpenFile(s.dayFile(day), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("open history file: %w", err)
+		}
+		enc := json.NewEncoder(f)
+		for _, sample := range group {
+			if err := enc.Encode(sample); err != nil {
+				f.Close()
+				return fmt.Errorf("write sample: %w", err)
+			}
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("close history file: %w", err)
+		}
+	}
+	return nil
+}
+
+// Merge inserts samples into the store, replacing existing samples with the
+// same timestamp and keeping every day file sorted. It returns the number of
+// samples that were not already present.
+func (s *Store) Merge(samples []Sample) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	added := 0
+	for day, group := range groupByDay(samples) {
+		existing, err := readFile(s.dayFile(day))
+		if err != nil && !os.IsNotExist(err) {
+			return added, err
+		}
+
+		byTime := make(map[int64]Sample, len(existing)+len(group))
+		for _, sample := range existing {
+			byTime[sample.Time.UnixNano()] = sample
+		}
+		for _, sample := range group {
+			if _, ok := byTime[sample.Time.UnixNano()]; !ok {
+				added++
+			}
+			byTime[sample.Time.UnixNano()] = sample
+		}
+
+		merged := make([]Sample, 0, len(byTime))
+		for _, sample := range byTime {
+			merged = append(merged, sample)
+		}
+		sortSamples(merged)
+
+		if err := writeFile(s.dayFile(day), merged); err != nil {
+			return added, err
+		}
+	}
+	return added, nil
+}
+
+// Range returns all samples with from <= Time < to, in chronological order.
+// A zero from or to leaves that side of the range open.
+func (s *Store) Range(from, to time.Time) ([]Sample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read history dir: %w", err)
+	}
+
+	var result []Sample
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".jsonl")
+		if !ok {
+			continue
+		}
+		day, err := time.ParseInLocation(time.DateOnly, name, time.Local)
+		if err != nil {
+			continue
+		}
+		if !from.IsZero() && !day.AddDate(0, 0, 1).After(from) {
+			continue
+		}
+		if !to.IsZero() && !day.Before(to) {
+			continue
+		}
+
+		samples, err := readFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, sample := range samples {
+			if !from.IsZero() && sample.Time.Before(from) {
+				continue
+			}
+			if !to.IsZero() && !sample.Time.Before(to) {
+				continue
+			}
+			result = append(result, sample)
+		}
+	}
+
+	sortSamples(result)
+	return result, nil
+}
+
+func groupByDay(samples []Sample) map[string][]Sample {
+	groups := make(map[string][]Sample)
+	for _, sample := range samples {
+		day := sample.Time.Local().Format(time.DateOnly)
+		groups[day] = append(groups[day], sample)
+	}
+	return groups
+}
+
+func sortSamples(samples []Sample) {
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+}
+
+func readFile(path string) ([]Sample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var samples []Sample
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var sample Sample
+		if err := json.Unmarshal(line, &sample); err != nil {
+			// A torn write at the end of a file must not make the whole day unreadable.
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return samples, nil
+}
+
+func writeFile(path string, samples []Sample) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".history-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, sample := range samples {
+		if err := enc.Encode(sample); err != nil {
+			tmp.Close()
+			return fmt.Errorf("write sample: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write history file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close history file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("chmod history file: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// FromSnapshot builds a sample from the latest readings of a Watcher.
+func FromSnapshot(snap apsystems.Snapshot) Sample {
+	return NewSample(snap.Statistics, snap.Limit, snap.Status, snap.Alarm)
+}
@@ -0,0 +1,118 @@
+// Package i18n translates the messages of the TUI and CLI. Each language is
+// a catalog in locales/ mapping message IDs to fmt format strings; messages
+// missing from a catalog fall back to English.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+//go:embed locales/*.json
+var files embed.FS
+
+// Default is the language of messages missing from a catalog.
+const Default = "en"
+
+var catalogs = sync.OnceValue(func() map[string]map[string]string {
+	entries, err := files.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	catalogs := make(map[string]map[string]string)
+	for _, e := range entries {
+		data, err := files.ReadFile("locales/" + e.Name())
+		if err != nil {
+			panic(err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: %s: %v", e.Name(), err))
+		}
+		catalogs[strings.TrimSuffix(e.Name(), path.Ext(e.Name()))] = messages
+	}
+	return catalogs
+})
+
+// Languages lists the languages with a catalog.
+func Languages() []string {
+	var langs []string
+	for lang := range catalogs() {
+		langs = append(langs, lang)
+	}
+	slices.Sort(langs)
+	return langs
+}
+
+// Printer formats messages in one language.
+type Printer struct {
+	lang     string
+	messages map[string]string
+}
+
+// New returns a printer for a language such as "de" or "de_DE.UTF-8". An
+// empty language is taken from LC_ALL, LC_MESSAGES or LANG, falling back to
+// English if there is no catalog for it; an explicit language without a
+// catalog is an error.
+func New(lang string) (Printer, error) {
+	explicit := lang != ""
+	if !explicit {
+		lang = env()
+	}
+	code := parse(lang)
+	messages, ok := catalogs()[code]
+	if !ok {
+		if explicit {
+			return Printer{}, fmt.Errorf("unsupported language %q (available: %s)", lang, strings.Join(Languages(), ", "))
+		}
+		code, messages = Default, catalogs()[Default]
+	}
+	return Printer{lang: code, messages: messages}, nil
+}
+
+// env returns the language of messages the way setlocale resolves it.
+func env() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// parse reduces a locale to its lower-case language.
+func parse(locale string) string {
+	lang, _, _ := strings.Cut(strings.ReplaceAll(locale, "-", "_"), "_")
+	lang, _, _ = strings.Cut(lang, ".")
+	lang, _, _ = strings.Cut(lang, "@")
+	return strings.ToLower(lang)
+}
+
+// Lang returns the language of the printer.
+func (p Printer) Lang() string {
+	if p.lang == "" {
+		return Default
+	}
+	return p.lang
+}
+
+// T returns the message with the given ID, formatted with args. An unknown
+// ID is returned as is, so that a missing message stays noticeable.
+func (p Printer) T(id string, args ...any) string {
+	msg, ok := p.messages[id]
+	if !ok {
+		if msg, ok = catalogs()[Default][id]; !ok {
+			msg = id
+		}
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
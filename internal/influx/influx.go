@@ -0,0 +1,333 @@
+package influx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/internal/history"
+)
+
+// Config describes the InfluxDB endpoint. Both InfluxDB 1.x and the 1.x
+// compatible /write API of InfluxDB 2.x are supported; with 2.x the database
+// maps to a bucket and Token is an API token.
+type Config struct {
+	URL             string // base URL, e.g. http://localhost:8086
+	Database        string
+	RetentionPolicy string
+	Token           string
+	Measurement     string
+	Tags            map[string]string
+
+	// BatchSize is the number of buffered lines that triggers a flush.
+	BatchSize int
+	// SpoolDir holds lines that could not be delivered. Empty disables spooling.
+	SpoolDir string
+
+	HTTPClient *http.Client
+}
+
+// errRejected marks writes InfluxDB refused because of their content. Retrying
+// them would never succeed, so they are dropped instead of spooled.
+var errRejected = errors.New("rejected by InfluxDB")
+
+// errTooLarge marks writes InfluxDB refused because of their size. They are
+// split and sent again.
+var errTooLarge = errors.New("request too large for InfluxDB")
+
+// Field adds a derived field to every line.
+type Field struct {
+	Key   string
+	Value func(history.Sample) float64
+}
+
+// Writer batches samples as line protocol and posts them to InfluxDB.
+type Writer struct {
+	cfg      Config
+	endpoint string
+	fields   []Field
+
+	mu      sync.Mutex
+	pending []string
+}
+
+func NewWriter(cfg Config, fields ...Field) (*Writer, error) {
+	base, err := url.Parse(strings.TrimRight(cfg.URL, "/"))
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid InfluxDB URL %q", cfg.URL)
+	}
+	if cfg.Database == "" {
+		return nil, fmt.Errorf("database is required")
+	}
+	if cfg.Measurement == "" {
+		cfg.Measurement = "ez1"
+	}
+	// Line protocol has no empty tags; InfluxDB would refuse every line.
+	for k, v := range cfg.Tags {
+		if k == "" || v == "" {
+			return nil, fmt.Errorf("invalid tag %q=%q: key and value must not be empty", k, v)
+		}
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 50
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.SpoolDir != "" {
+		if err := os.MkdirAll(cfg.SpoolDir, 0o755); err != nil {
+			return nil, fmt.Errorf("create spool dir: %w", err)
+		}
+	}
+
+	q := url.Values{}
+	q.Set("db", cfg.Database)
+	q.Set("precision", "s")
+	if cfg.RetentionPolicy != "" {
+		q.Set("rp", cfg.RetentionPolicy)
+	}
+	base.Path += "/write"
+	base.RawQuery = q.Encode()
+
+	return &Writer{cfg: cfg, endpoint: base.String(), fields: fields}, nil
+}
+
+// Add buffers a sample and flushes once the batch is full.
+func (w *Writer) Add(ctx context.Context, s history.Sample) error {
+	w.mu.Lock()
+	w.pending = append(w.pending, w.Line(s))
+	full := len(w.pending) >= w.cfg.BatchSize
+	w.mu.Unlock()
+
+	if full {
+		return w.Flush(ctx)
+	}
+	return nil
+}
+
+// Flush delivers spooled lines followed by the current batch. If the endpoint
+// is unreachable the batch is spooled to disk and replayed on the next flush.
+func (w *Writer) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	batch := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+
+	if err := w.replay(ctx); err != nil {
+		return w.spoolOrKeep(batch, err)
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	if n, err := w.postSplit(ctx, batch); err != nil {
+		if errors.Is(err, errRejected) {
+			return err
+		}
+		return w.spoolOrKeep(batch[n:], err)
+	}
+	return nil
+}
+
+func (w *Writer) spoolOrKeep(batch []string, cause error) error {
+	if len(batch) == 0 {
+		return cause
+	}
+	if w.cfg.SpoolDir == "" {
+		// Without a spool keep the batch in memory for the next attempt.
+		w.mu.Lock()
+		w.pending = append(batch, w.pending...)
+		w.mu.Unlock()
+		return cause
+	}
+	if err := w.spool(batch); err != nil {
+		return fmt.Errorf("%w (spooling failed: %v)", cause, err)
+	}
+	return cause
+}
+
+func (w *Writer) post(ctx context.Context, lines []string) error {
+	body := strings.Join(lines, "\n") + "\n"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.endpoint, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if w.cfg.Token != "" {
+		req.Header.Set("Authorization", "Token "+w.cfg.Token)
+	}
+
+	resp, err := w.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("InfluxDB error (status %d): %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+		switch resp.StatusCode {
+		case http.StatusBadRequest:
+			return fmt.Errorf("%w: %w", errRejected, err)
+		case http.StatusRequestEntityTooLarge:
+			return fmt.Errorf("%w: %w", errTooLarge, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// postSplit posts lines, halving batches that are too large until they are
+// accepted. It returns the number of lines delivered or rejected before an
+// error that is worth retrying; a single line that is too large is rejected.
+func (w *Writer) postSplit(ctx context.Context, lines []string) (int, error) {
+	err := w.post(ctx, lines)
+	if !errors.Is(err, errTooLarge) {
+		if err != nil && !errors.Is(err, errRejected) {
+			return 0, err
+		}
+		return len(lines), err
+	}
+	if len(lines) == 1 {
+		return 1, fmt.Errorf("%w: %w", errRejected, err)
+	}
+
+	half := len(lines) / 2
+	first, err := w.postSplit(ctx, lines[:half])
+	if first < half {
+		return first, err
+	}
+	second, secondErr := w.postSplit(ctx, lines[half:])
+	if secondErr != nil {
+		err = secondErr
+	}
+	return first + second, err
+}
+
+func (w *Writer) spoolFile() string {
+	return filepath.Join(w.cfg.SpoolDir, "spool.lp")
+}
+
+func (w *Writer) spool(lines []string) error {
+	f, err := os.OpenFile(w.spoolFile(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// replay posts spooled lines in batches. Delivered lines are removed from the
+// spool so that a failure halfway through does not resend them.
+func (w *Writer) replay(ctx context.Context) error {
+	if w.cfg.SpoolDir == "" {
+		return nil
+	}
+	data, err := os.ReadFile(w.spoolFile())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read spool: %w", err)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	for len(lines) > 0 {
+		n := min(len(lines), w.cfg.BatchSize*10)
+		if done, err := w.postSplit(ctx, lines[:n]); err != nil && !errors.Is(err, errRejected) {
+			lines = lines[done:]
+			if rewriteErr := w.rewriteSpool(lines); rewriteErr != nil {
+				return fmt.Errorf("%w (rewriting spool failed: %v)", err, rewriteErr)
+			}
+			return err
+		}
+		lines = lines[n:]
+	}
+	return os.Remove(w.spoolFile())
+}
+
+func (w *Writer) rewriteSpool(lines []string) error {
+	tmp := w.spoolFile() + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.spoolFile())
+}
+
+// Line encodes a sample as a single line of InfluxDB line protocol.
+func (w *Writer) Line(s history.Sample) string {
+	var b strings.Builder
+	b.WriteString(escape(w.cfg.Measurement, ", "))
+
+	keys := make([]string, 0, len(w.cfg.Tags))
+	for k := range w.cfg.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteByte(',')
+		b.WriteString(escape(k, ",= "))
+		b.WriteByte('=')
+		b.WriteString(escape(w.cfg.Tags[k], ",= "))
+	}
+
+	fields := []string{
+		"p1=" + strconv.Itoa(s.P1) + "i",
+		"p2=" + strconv.Itoa(s.P2) + "i",
+		"power=" + strconv.Itoa(s.P1+s.P2) + "i",
+		"e1=" + formatFloat(s.E1),
+		"e2=" + formatFloat(s.E2),
+		"energy_today=" + formatFloat(s.E1+s.E2),
+		"te1=" + formatFloat(s.Te1),
+		"te2=" + formatFloat(s.Te2),
+		"energy_lifetime=" + formatFloat(s.Te1+s.Te2),
+		"limit=" + strconv.Itoa(s.Limit) + "i",
+		"status=" + strconv.Itoa(s.Status) + "i",
+		"alarms=" + strconv.Itoa(int(s.Alarms)) + "i",
+	}
+	for _, f := range w.fields {
+		fields = append(fields, escape(f.Key, ",= ")+"="+formatFloat(f.Value(s)))
+	}
+
+	b.WriteByte(' ')
+	b.WriteString(strings.Join(fields, ","))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(s.Time.Unix(), 10))
+	return b.String()
+}
+
+func escape(s, chars string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(chars, r) || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
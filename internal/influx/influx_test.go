@@ -0,0 +1,221 @@
+package influx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/internal/history"
+)
+
+// fakeInflux is a /write endpoint that answers 413 to requests with more
+// than maxLines lines, unless it is 0, and fails the first failures requests
+// with status.
+type fakeInflux struct {
+	maxLines int
+	status   int
+	failures int
+
+	mu        sync.Mutex
+	requests  []int
+	delivered []string
+}
+
+func (f *fakeInflux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.URL.Path != "/write" || r.URL.Query().Get("db") != "solar":
+		http.Error(w, "wrong endpoint", http.StatusNotFound)
+	case f.maxLines != 0 && len(lines) > f.maxLines:
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+	case f.failures > 0:
+		f.failures--
+		http.Error(w, "failing", f.status)
+	default:
+		f.requests = append(f.requests, len(lines))
+		f.delivered = append(f.delivered, lines...)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		name      string
+		batchSize int
+		samples   int
+		spool     bool
+		// maxLines, status and failures configure the fakeInflux.
+		maxLines, status, failures int
+		// wantErrs is the number of Add and Flush calls that fail.
+		wantErrs     int
+		wantRejected bool
+		// wantSpooled is the number of spooled lines after the first error.
+		wantSpooled  int
+		wantRequests []int
+		// wantDropped is the number of lines InfluxDB refused for good.
+		wantDropped int
+	}{
+		{
+			name:         "batching",
+			batchSize:    3,
+			samples:      7,
+			wantRequests: []int{3, 3, 1},
+		},
+		{
+			name:         "split on 413",
+			batchSize:    8,
+			samples:      8,
+			maxLines:     3,
+			wantRequests: []int{2, 2, 2, 2},
+		},
+		{
+			name:         "single line too large",
+			batchSize:    2,
+			samples:      2,
+			maxLines:     -1, // even a single line
+			wantErrs:     1,
+			wantRejected: true,
+			wantDropped:  2,
+		},
+		{
+			name:         "rejected batch is dropped",
+			batchSize:    2,
+			samples:      4,
+			spool:        true,
+			status:       http.StatusBadRequest,
+			failures:     1,
+			wantErrs:     1,
+			wantRejected: true,
+			wantRequests: []int{2},
+			wantDropped:  2,
+		},
+		{
+			name:         "spool and replay",
+			batchSize:    2,
+			samples:      6,
+			spool:        true,
+			status:       http.StatusServiceUnavailable,
+			failures:     1,
+			wantErrs:     1,
+			wantSpooled:  2,
+			wantRequests: []int{2, 2, 2},
+		},
+		{
+			name:         "kept in memory without spool",
+			batchSize:    2,
+			samples:      4,
+			status:       http.StatusServiceUnavailable,
+			failures:     1,
+			wantErrs:     1,
+			wantRequests: []int{3, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			influx := &fakeInflux{maxLines: tt.maxLines, status: tt.status, failures: tt.failures}
+			server := httptest.NewServer(influx)
+			defer server.Close()
+
+			cfg := Config{
+				URL:       server.URL,
+				Database:  "solar",
+				Tags:      map[string]string{"device_id": "E17000000001"},
+				BatchSize: tt.batchSize,
+			}
+			if tt.spool {
+				cfg.SpoolDir = t.TempDir()
+			}
+			w, err := NewWriter(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			start := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+			var lines []string
+			var errs []error
+			for i := range tt.samples {
+				s := history.Sample{Time: start.Add(time.Duration(i) * time.Second), P1: 100 + i, P2: 200}
+				lines = append(lines, w.Line(s))
+				if err := w.Add(ctx, s); err != nil {
+					errs = append(errs, err)
+					if len(errs) == 1 && tt.spool {
+						if got := countSpooled(t, w); got != tt.wantSpooled {
+							t.Errorf("spooled %d lines after %v, want %d", got, err, tt.wantSpooled)
+						}
+					}
+				}
+			}
+			if err := w.Flush(ctx); err != nil {
+				errs = append(errs, err)
+			}
+
+			if len(errs) != tt.wantErrs {
+				t.Fatalf("got errors %v, want %d", errs, tt.wantErrs)
+			}
+			for _, err := range errs {
+				if errors.Is(err, errRejected) != tt.wantRejected {
+					t.Errorf("error %v: rejected = %v, want %v", err, !tt.wantRejected, tt.wantRejected)
+				}
+			}
+			if !slices.Equal(influx.requests, tt.wantRequests) {
+				t.Errorf("accepted requests with %v lines, want %v", influx.requests, tt.wantRequests)
+			}
+			// Delivered lines keep their order, and nothing is sent twice.
+			if want := lines[tt.wantDropped:]; !slices.Equal(influx.delivered, want) {
+				t.Errorf("delivered\n%s\nwant\n%s", strings.Join(influx.delivered, "\n"), strings.Join(want, "\n"))
+			}
+			if tt.spool {
+				if got := countSpooled(t, w); got != 0 {
+					t.Errorf("%d lines left in the spool", got)
+				}
+			}
+		})
+	}
+}
+
+func countSpooled(t *testing.T, w *Writer) int {
+	t.Helper()
+	data, err := os.ReadFile(w.spoolFile())
+	if os.IsNotExist(err) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "\n")
+}
+
+func TestNewWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"valid", Config{URL: "http://localhost:8086", Database: "solar", Tags: map[string]string{"site": "roof"}}, false},
+		{"empty tag value", Config{URL: "http://localhost:8086", Database: "solar", Tags: map[string]string{"device_id": ""}}, true},
+		{"empty tag key", Config{URL: "http://localhost:8086", Database: "solar", Tags: map[string]string{"": "roof"}}, true},
+		{"missing database", Config{URL: "http://localhost:8086"}, true},
+		{"invalid URL", Config{URL: "localhost", Database: "solar"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewWriter(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewWriter() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
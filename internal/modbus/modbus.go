@@ -0,0 +1,199 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Exception is a Modbus exception code returned to the client.
+type Exception byte
+
+const (
+	IllegalFunction     Exception = 0x01
+	IllegalDataAddress  Exception = 0x02
+	IllegalDataValue    Exception = 0x03
+	ServerDeviceFailure Exception = 0x04
+)
+
+func (e Exception) Error() string {
+	switch e {
+	case IllegalFunction:
+		return "illegal function"
+	case IllegalDataAddress:
+		return "illegal data address"
+	case IllegalDataValue:
+		return "illegal data value"
+	case ServerDeviceFailure:
+		return "server device failure"
+	}
+	return fmt.Sprintf("exception 0x%02x", byte(e))
+}
+
+const (
+	funcReadHoldingRegisters   = 0x03
+	funcReadInputRegisters     = 0x04
+	funcWriteSingleRegister    = 0x06
+	funcWriteMultipleRegisters = 0x10
+
+	maxReadCount  = 125
+	maxWriteCount = 123
+)
+
+// Handler serves register reads and writes. Errors other than Exception are
+// reported to the client as ServerDeviceFailure.
+type Handler interface {
+	ReadRegisters(unit uint8, addr, count uint16) ([]uint16, error)
+	WriteRegisters(unit uint8, addr uint16, values []uint16) error
+}
+
+// Server is a Modbus TCP server.
+type Server struct {
+	Handler     Handler
+	IdleTimeout time.Duration
+}
+
+// Serve accepts connections on ln until ctx is cancelled.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("accept: %w", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	idle := s.IdleTimeout
+	if idle == 0 {
+		idle = 2 * time.Minute
+	}
+
+	header := make([]byte, 7)
+	for {
+		conn.SetReadDeadline(time.Now().Add(idle))
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		txID := binary.BigEndian.Uint16(header[0:2])
+		protocol := binary.BigEndian.Uint16(header[2:4])
+		length := binary.BigEndian.Uint16(header[4:6])
+		unit := header[6]
+		if protocol != 0 || length < 2 || length > 254 {
+			return
+		}
+
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			return
+		}
+
+		resp := s.handle(unit, pdu)
+
+		out := make([]byte, 7+len(resp))
+		binary.BigEndian.PutUint16(out[0:2], txID)
+		binary.BigEndian.PutUint16(out[4:6], uint16(len(resp)+1))
+		out[6] = unit
+		copy(out[7:], resp)
+		if _, err := conn.Write(out); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) handle(unit uint8, pdu []byte) []byte {
+	fn := pdu[0]
+	data := pdu[1:]
+
+	switch fn {
+	case funcReadHoldingRegisters, funcReadInputRegisters:
+		if len(data) != 4 {
+			return exception(fn, IllegalDataValue)
+		}
+		addr := binary.BigEndian.Uint16(data[0:2])
+		count := binary.BigEndian.Uint16(data[2:4])
+		if count == 0 || count > maxReadCount {
+			return exception(fn, IllegalDataValue)
+		}
+		values, err := s.Handler.ReadRegisters(unit, addr, count)
+		if err != nil {
+			return exception(fn, toException(err))
+		}
+		resp := make([]byte, 2+2*len(values))
+		resp[0] = fn
+		resp[1] = byte(2 * len(values))
+		for i, v := range values {
+			binary.BigEndian.PutUint16(resp[2+2*i:], v)
+		}
+		return resp
+
+	case funcWriteSingleRegister:
+		if len(data) != 4 {
+			return exception(fn, IllegalDataValue)
+		}
+		addr := binary.BigEndian.Uint16(data[0:2])
+		value := binary.BigEndian.Uint16(data[2:4])
+		if err := s.Handler.WriteRegisters(unit, addr, []uint16{value}); err != nil {
+			return exception(fn, toException(err))
+		}
+		return append([]byte{fn}, data...)
+
+	case funcWriteMultipleRegisters:
+		if len(data) < 5 {
+			return exception(fn, IllegalDataValue)
+		}
+		addr := binary.BigEndian.Uint16(data[0:2])
+		count := binary.BigEndian.Uint16(data[2:4])
+		byteCount := int(data[4])
+		if count == 0 || count > maxWriteCount || byteCount != 2*int(count) || len(data) != 5+byteCount {
+			return exception(fn, IllegalDataValue)
+		}
+		values := make([]uint16, count)
+		for i := range values {
+			values[i] = binary.BigEndian.Uint16(data[5+2*i:])
+		}
+		if err := s.Handler.WriteRegisters(unit, addr, values); err != nil {
+			return exception(fn, toException(err))
+		}
+		return append([]byte{fn}, data[0:4]...)
+	}
+
+	return exception(fn, IllegalFunction)
+}
+
+func exception(fn byte, e Exception) []byte {
+	return []byte{fn | 0x80, byte(e)}
+}
+
+func toException(err error) Exception {
+	var e Exception
+	if errors.As(err, &e) {
+		return e
+	}
+	return ServerDeviceFailure
+}
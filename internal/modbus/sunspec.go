@@ -0,0 +1,390 @@
+package modbus
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/pkg/apsystems"
+)
+
+// BaseAddress is the register address of the SunSpec "SunS" marker.
+const BaseAddress = 40000
+
+// Register offsets relative to BaseAddress. The map consists of the common
+// model (1), the single phase inverter model (101), basic settings (121) and
+// immediate controls (123).
+const (
+	offCommon   = 2
+	offInverter = offCommon + 2 + 66
+	offSettings = offInverter + 2 + 50
+	offControls = offSettings + 2 + 30
+	offEnd      = offControls + 2 + 24
+	mapSize     = offEnd + 2
+
+	// Writable registers in the immediate controls model.
+	regConnWinTms      = offControls + 2
+	regConn            = offControls + 2 + 2
+	regWMaxLimPct      = offControls + 2 + 3
+	regWMaxLimEna      = offControls + 2 + 7
+	wMaxLimPctScale    = 10 // WMaxLimPct_SF = -1
+	unimplementedU16   = 0xFFFF
+	unimplementedI16   = 0x8000
+	unimplementedScale = 0x8000
+)
+
+// Operating states (St) of the inverter model.
+const (
+	stateOff       = 1
+	stateSleeping  = 2
+	stateMPPT      = 4
+	stateThrottled = 5
+	stateFault     = 7
+)
+
+// Event bits (Evt1) of the inverter model.
+const (
+	evtGridDisconnect = 1 << 4
+	evtManualShutdown = 1 << 6
+)
+
+// Vendor event bits (EvtVnd1) mirror the EZ1 alarm flags.
+const (
+	evtVndGridFault = 1 << iota
+	evtVndPV1ShortCircuit
+	evtVndPV2ShortCircuit
+	evtVndOutputError
+	// evtVndAlarmsUnknown is set while any alarm flag is not reported.
+	evtVndAlarmsUnknown
+)
+
+// Device exposes the latest inverter readings as SunSpec registers and
+// forwards control writes to the inverter.
+type Device struct {
+	client *apsystems.Client
+	unit   uint8
+
+	mu       sync.Mutex
+	info     *apsystems.DeviceInfo
+	output   *apsystems.OutputData
+	alarm    *apsystems.AlarmInfo
+	status   *apsystems.PowerStatus
+	limit    *apsystems.PowerLimit
+	limitEna uint16
+	regs     []uint16
+}
+
+func NewDevice(client *apsystems.Client, unit uint8) *Device {
+	return &Device{client: client, unit: unit}
+}
+
+// Poll refreshes the readings every interval until ctx is cancelled. Errors
+// are passed to onError and keep the previous readings in place.
+func (d *Device) Poll(ctx context.Context, interval time.Duration, onError func(error)) {
+	watcher := apsystems.NewWatcher(d.client,
+		apsystems.WithInterval(apsystems.EndpointOutput, interval),
+		apsystems.WithInterval(apsystems.EndpointAlarm, interval),
+		apsystems.WithInterval(apsystems.EndpointPowerStatus, interval),
+		apsystems.WithInterval(apsystems.EndpointMaxPower, interval),
+	)
+	watcher.OnUpdate(func(u apsystems.Update) {
+		if u.Err != nil {
+			onError(u.Err)
+			return
+		}
+		d.apply(u)
+	})
+	watcher.Run(ctx)
+}
+
+func (d *Device) apply(u apsystems.Update) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch u.Endpoint {
+	case apsystems.EndpointOutput:
+		d.output = u.Output
+	case apsystems.EndpointDeviceInfo:
+		d.info = u.DeviceInfo
+	case apsystems.EndpointAlarm:
+		d.alarm = u.Alarm
+	case apsystems.EndpointPowerStatus:
+		d.status = u.Status
+	case apsystems.EndpointMaxPower:
+		if d.limit == nil {
+			// Start with the limit enabled if one is in effect.
+			d.limitEna = boolReg(int(u.Limit.Data.MaxPower) < d.wMax())
+		}
+		d.limit = u.Limit
+	}
+	d.build()
+}
+
+func (d *Device) acceptsUnit(unit uint8) bool {
+	return unit == d.unit || unit == 0 || unit == 0xFF
+}
+
+func (d *Device) ReadRegisters(unit uint8, addr, count uint16) ([]uint16, error) {
+	if !d.acceptsUnit(unit) {
+		return nil, IllegalDataAddress
+	}
+	start := int(addr) - BaseAddress
+	if start < 0 || start+int(count) > mapSize {
+		return nil, IllegalDataAddress
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.regs == nil {
+		return nil, ServerDeviceFailure
+	}
+	return append([]uint16(nil), d.regs[start:start+int(count)]...), nil
+}
+
+func (d *Device) WriteRegisters(unit uint8, addr uint16, values []uint16) error {
+	if !d.acceptsUnit(unit) {
+		return IllegalDataAddress
+	}
+	start := int(addr) - BaseAddress
+	end := start + len(values)
+	if start < regConnWinTms || end > regWMaxLimEna+1 {
+		return IllegalDataAddress
+	}
+
+	d.mu.Lock()
+	if d.regs == nil || d.limit == nil {
+		d.mu.Unlock()
+		return ServerDeviceFailure
+	}
+	conn, pct, ena := d.regs[regConn], d.regs[regWMaxLimPct], d.limitEna
+	var setConn, setLimit bool
+	for i, v := range values {
+		switch start + i {
+		case regConn:
+			conn, setConn = v, true
+		case regWMaxLimPct:
+			pct, setLimit = v, true
+		case regWMaxLimEna:
+			ena, setLimit = v, true
+		}
+		// Window, revert and ramp times are accepted but not supported by the EZ1.
+	}
+	wMax, wMin := d.wMax(), d.wMin()
+	d.mu.Unlock()
+
+	// Conn reads as unimplemented while the status is unknown, so it is
+	// only checked when written.
+	if (setConn && conn > 1) || ena > 1 || pct > 100*wMaxLimPctScale {
+		return IllegalDataValue
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if setConn {
+		status := "OFF"
+		if conn == 1 {
+			status = "ON"
+		}
+		if err := d.client.SetDevicePowerStatus(ctx, status); err != nil {
+			return fmt.Errorf("%w: %v", ServerDeviceFailure, err)
+		}
+	}
+
+	var watts int
+	if setLimit {
+		watts = wMax
+		if ena == 1 {
+			watts = int(math.Round(float64(pct) / (100 * wMaxLimPctScale) * float64(wMax)))
+			watts = min(max(watts, wMin), wMax)
+		}
+		if err := d.client.SetMaxPower(ctx, watts); err != nil {
+			return fmt.Errorf("%w: %v", ServerDeviceFailure, err)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if setConn {
+		d.status = &apsystems.PowerStatus{}
+		d.status.Data.Status = apsystems.PowerState(1 - conn)
+	}
+	if setLimit {
+		d.limitEna = ena
+		d.limit = &apsystems.PowerLimit{}
+		d.limit.Data.MaxPower = apsystems.StringInt(watts)
+	}
+	d.build()
+	return nil
+}
+
+func (d *Device) wMax() int {
+	if d.info != nil && d.info.Data.MaxPower > 0 {
+		return int(d.info.Data.MaxPower)
+	}
+	return 800
+}
+
+func (d *Device) wMin() int {
+	if d.info != nil && d.info.Data.MinPower > 0 {
+		return int(d.info.Data.MinPower)
+	}
+	return 30
+}
+
+// build renders the register map from the current readings. d.mu must be held.
+func (d *Device) build() {
+	if d.output == nil {
+		return
+	}
+	r := make([]uint16, mapSize)
+	r[0], r[1] = 0x5375, 0x6e53 // "SunS"
+
+	// Common model
+	r[offCommon], r[offCommon+1] = 1, 66
+	c := r[offCommon+2:]
+	putString(c[0:16], "APsystems")
+	putString(c[16:32], "EZ1-M")
+	if d.info != nil {
+		putString(c[40:48], d.info.Data.Firmware)
+		putString(c[48:64], d.info.Data.DeviceID)
+	}
+	c[64] = uint16(d.unit)
+	c[65] = unimplementedI16
+
+	// Single phase inverter model
+	r[offInverter], r[offInverter+1] = 101, 50
+	inv := r[offInverter+2:]
+	fill(inv[0:50], unimplementedU16)
+	for _, i := range []int{4, 11, 15, 17, 19, 21, 26, 28, 30, 35} {
+		inv[i] = unimplementedScale
+	}
+	for _, i := range []int{16, 18, 20, 29, 31, 32, 33, 34} {
+		inv[i] = unimplementedI16
+	}
+	out := d.output.Data
+	power := int(out.P1 + out.P2)
+	inv[12] = uint16(int16(min(power, math.MaxInt16))) // W
+	inv[13] = 0                                        // W_SF
+	wh := uint32(math.Round(float64(out.Te1+out.Te2) * 1000))
+	inv[22], inv[23] = uint16(wh>>16), uint16(wh) // WH
+	inv[24] = 0                                   // WH_SF
+
+	state, evt1, evtVnd := uint16(stateMPPT), uint32(0), uint32(0)
+	status := apsystems.PowerUnknown
+	if d.status != nil {
+		status = d.status.Data.Status
+	}
+	if d.alarm == nil {
+		evtVnd |= evtVndAlarmsUnknown
+	} else {
+		a := d.alarm.Data
+		for _, s := range []apsystems.AlarmState{a.Og, a.Isce1, a.Isce2, a.Oe} {
+			if !s.Known() {
+				evtVnd |= evtVndAlarmsUnknown
+			}
+		}
+		if a.Og.Active() {
+			evt1 |= evtGridDisconnect
+			evtVnd |= evtVndGridFault
+		}
+		if a.Isce1.Active() {
+			evtVnd |= evtVndPV1ShortCircuit
+		}
+		if a.Isce2.Active() {
+			evtVnd |= evtVndPV2ShortCircuit
+		}
+		if a.Oe.Active() {
+			evtVnd |= evtVndOutputError
+		}
+	}
+	switch {
+	case status == apsystems.PowerOff:
+		state = stateOff
+		evt1 |= evtManualShutdown
+	case evtVnd&^evtVndAlarmsUnknown != 0:
+		state = stateFault
+	case power == 0:
+		state = stateSleeping
+	case d.limit != nil && power >= int(d.limit.Data.MaxPower):
+		state = stateThrottled
+	}
+	inv[36] = state
+	inv[37] = unimplementedU16 // StVnd: raw EZ1 status, if reported
+	if status.Known() {
+		inv[37] = uint16(status)
+	}
+	putUint32(inv[38:40], evt1)
+	putUint32(inv[40:42], 0)
+	putUint32(inv[42:44], evtVnd)
+	for i := 44; i < 50; i += 2 {
+		putUint32(inv[i:i+2], 0)
+	}
+
+	// Basic settings model
+	r[offSettings], r[offSettings+1] = 121, 30
+	set := r[offSettings+2:]
+	fill(set[0:30], unimplementedU16)
+	for _, i := range []int{2, 6, 7, 8, 9, 11, 12, 13, 14} {
+		set[i] = unimplementedI16
+	}
+	fill(set[21:30], unimplementedScale)
+	set[0] = uint16(d.wMax()) // WMax
+	set[20] = 0               // WMax_SF
+
+	// Immediate controls model
+	r[offControls], r[offControls+1] = 123, 24
+	ctl := r[offControls+2:]
+	fill(ctl[0:24], unimplementedU16)
+	fill(ctl[4:7], 0)
+	ctl[0], ctl[1] = 0, 0 // Conn_WinTms, Conn_RvrtTms
+	if status.Known() {
+		ctl[2] = boolReg(status != apsystems.PowerOff) // Conn
+	}
+	if d.limit != nil {
+		pct := float64(d.limit.Data.MaxPower) / float64(d.wMax()) * 100 * wMaxLimPctScale
+		ctl[3] = uint16(math.Round(min(pct, 100*wMaxLimPctScale)))
+	}
+	ctl[7] = d.limitEna
+	for _, i := range []int{8, 13, 14, 15} {
+		ctl[i] = unimplementedI16
+	}
+	ctl[21] = 0xFFFF // WMaxLimPct_SF = -1
+	ctl[22], ctl[23] = unimplementedScale, unimplementedScale
+
+	r[offEnd], r[offEnd+1] = 0xFFFF, 0
+	d.regs = r
+}
+
+func putString(regs []uint16, s string) {
+	b := []byte(s)
+	for i := range regs {
+		var hi, lo byte
+		if 2*i < len(b) {
+			hi = b[2*i]
+		}
+		if 2*i+1 < len(b) {
+			lo = b[2*i+1]
+		}
+		regs[i] = uint16(hi)<<8 | uint16(lo)
+	}
+}
+
+func putUint32(regs []uint16, v uint32) {
+	regs[0], regs[1] = uint16(v>>16), uint16(v)
+}
+
+func fill(regs []uint16, v uint16) {
+	for i := range regs {
+		regs[i] = v
+	}
+}
+
+func boolReg(b bool) uint16 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,159 @@
+package pvoutput
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultBaseURL = "https://pvoutput.org"
+
+// Status is a single live status report.
+type Status struct {
+	Time     time.Time
+	EnergyWh int // energy generated so far today
+	PowerW   int // average power over the interval
+}
+
+// Output is an end-of-day summary.
+type Output struct {
+	Date        time.Time
+	GeneratedWh int
+	PeakPowerW  int
+	PeakTime    time.Time
+}
+
+// RateLimitError is returned when PVOutput refuses a request because the
+// hourly request quota is used up.
+type RateLimitError struct {
+	Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Reset.IsZero() {
+		return "PVOutput rate limit exceeded"
+	}
+	return fmt.Sprintf("PVOutput rate limit exceeded until %s", e.Reset.Format("15:04"))
+}
+
+// Config holds the credentials and endpoint of a PVOutput system.
+type Config struct {
+	BaseURL  string
+	APIKey   string
+	SystemID string
+	// BatchSize is the maximum number of statuses per batch request
+	// (30 for regular accounts, 100 with donation).
+	BatchSize int
+
+	HTTPClient *http.Client
+}
+
+type Client struct {
+	cfg     Config
+	baseURL string
+}
+
+func NewClient(cfg Config) *Client {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 30
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Client{cfg: cfg, baseURL: strings.TrimRight(cfg.BaseURL, "/")}
+}
+
+func (c *Client) AddStatus(ctx context.Context, s Status) error {
+	form := url.Values{}
+	form.Set("d", s.Time.Format("20060102"))
+	form.Set("t", s.Time.Format("15:04"))
+	form.Set("v1", strconv.Itoa(s.EnergyWh))
+	form.Set("v2", strconv.Itoa(s.PowerW))
+	if err := c.post(ctx, "/service/r2/addstatus.jsp", form); err != nil {
+		return fmt.Errorf("add status: %w", err)
+	}
+	return nil
+}
+
+// AddBatchStatus uploads statuses in chunks of the configured batch size.
+// It returns the number of statuses that were accepted before an error.
+func (c *Client) AddBatchStatus(ctx context.Context, statuses []Status) (int, error) {
+	sent := 0
+	for len(statuses) > 0 {
+		n := min(len(statuses), c.cfg.BatchSize)
+		entries := make([]string, n)
+		for i, s := range statuses[:n] {
+			entries[i] = fmt.Sprintf("%s,%s,%d,%d", s.Time.Format("20060102"), s.Time.Format("15:04"), s.EnergyWh, s.PowerW)
+		}
+
+		form := url.Values{}
+		form.Set("data", strings.Join(entries, ";"))
+		if err := c.post(ctx, "/service/r2/addbatchstatus.jsp", form); err != nil {
+			return sent, fmt.Errorf("add batch status: %w", err)
+		}
+		sent += n
+		statuses = statuses[n:]
+	}
+	return sent, nil
+}
+
+func (c *Client) AddOutput(ctx context.Context, o Output) error {
+	form := url.Values{}
+	form.Set("d", o.Date.Format("20060102"))
+	form.Set("g", strconv.Itoa(o.GeneratedWh))
+	if o.PeakPowerW > 0 {
+		form.Set("pp", strconv.Itoa(o.PeakPowerW))
+		form.Set("pt", o.PeakTime.Format("15:04"))
+	}
+	if err := c.post(ctx, "/service/r2/addoutput.jsp", form); err != nil {
+		return fmt.Errorf("add output: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) post(ctx context.Context, path string, form url.Values) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Pvoutput-Apikey", c.cfg.APIKey)
+	req.Header.Set("X-Pvoutput-SystemId", c.cfg.SystemID)
+	req.Header.Set("X-Rate-Limit", "1")
+
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusForbidden && strings.Contains(string(body), "Exceeded")) {
+		rle := &RateLimitError{}
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-Rate-Limit-Reset"), 10, 64); err == nil {
+			rle.Reset = time.Unix(reset, 0)
+		}
+		return rle
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("API error (status %d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// IsRateLimited reports whether err was caused by the PVOutput rate limit.
+func IsRateLimited(err error) (*RateLimitError, bool) {
+	var rle *RateLimitError
+	ok := errors.As(err, &rle)
+	return rle, ok
+}
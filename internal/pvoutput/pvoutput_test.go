@@ -0,0 +1,176 @@
+package pvoutput
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/internal/history"
+)
+
+// fakePVOutput records the requests it accepts, one line each, and answers
+// with the rate limit error once limit requests have been accepted.
+type fakePVOutput struct {
+	mu       sync.Mutex
+	limit    int
+	requests []string
+}
+
+func (f *fakePVOutput) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Pvoutput-Apikey") != "key" || r.Header.Get("X-Pvoutput-SystemId") != "42" {
+		http.Error(w, "Unauthorized 401: Invalid API Key", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.limit > 0 && len(f.requests) >= f.limit {
+		w.Header().Set("X-Rate-Limit-Reset", strconv.FormatInt(time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC).Unix(), 10))
+		http.Error(w, "Forbidden 403: Exceeded 60 requests per hour", http.StatusForbidden)
+		return
+	}
+
+	var request string
+	switch name := strings.TrimSuffix(filepath.Base(r.URL.Path), ".jsp"); name {
+	case "addstatus":
+		request = fmt.Sprintf("%s %s %s v1=%s v2=%s", name, r.Form.Get("d"), r.Form.Get("t"), r.Form.Get("v1"), r.Form.Get("v2"))
+	case "addbatchstatus":
+		request = name + " " + r.Form.Get("data")
+	case "addoutput":
+		request = fmt.Sprintf("%s %s g=%s pp=%s pt=%s", name, r.Form.Get("d"), r.Form.Get("g"), r.Form.Get("pp"), r.Form.Get("pt"))
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	f.requests = append(f.requests, request)
+	fmt.Fprint(w, "OK 200: Added Status")
+}
+
+// minutes returns one sample per minute from start with a constant power of
+// 150 W and 2 Wh more energy each minute.
+func minutes(start time.Time, n int) []history.Sample {
+	samples := make([]history.Sample, n)
+	for i := range samples {
+		samples[i] = history.Sample{Time: start.Add(time.Duration(i) * time.Minute), P1: 100, P2: 50, E1: 0.002 * float64(i)}
+	}
+	return samples
+}
+
+func TestUploaderSync(t *testing.T) {
+	may31 := time.Date(2026, 5, 31, 10, 0, 0, 0, time.Local)
+	june1 := time.Date(2026, 6, 1, 8, 0, 0, 0, time.Local)
+	backfill := append(minutes(may31, 30), minutes(june1, 15)...)
+
+	tests := []struct {
+		name    string
+		samples []history.Sample
+		state   state
+		now     time.Time
+		// limit is the number of requests accepted before the rate limit
+		// hits, or 0 for none.
+		limit           int
+		wantRequests    []string
+		wantRateLimited bool
+		// wantResumed are the requests of a second sync with a new
+		// uploader and no rate limit.
+		wantResumed []string
+	}{
+		{
+			name:    "live status",
+			samples: minutes(june1.Add(4*time.Hour), 10),
+			state:   state{LastStatus: june1.Add(4*time.Hour + 5*time.Minute)},
+			now:     june1.Add(4*time.Hour + 12*time.Minute),
+			wantRequests: []string{
+				"addstatus 20260601 12:10 v1=18 v2=150",
+			},
+		},
+		{
+			name:    "backfill",
+			samples: backfill,
+			now:     june1.Add(16 * time.Minute),
+			wantRequests: []string{
+				"addbatchstatus 20260531,10:05,8,150;20260531,10:10,18,150;20260531,10:15,28,150;20260531,10:20,38,150",
+				"addbatchstatus 20260531,10:25,48,150;20260531,10:30,58,150;20260601,08:05,8,150;20260601,08:10,18,150",
+				"addbatchstatus 20260601,08:15,28,150",
+				"addoutput 20260531 g=58 pp=150 pt=10:00",
+			},
+		},
+		{
+			name:    "backfill resumes after rate limit",
+			samples: backfill,
+			now:     june1.Add(16 * time.Minute),
+			limit:   1,
+			wantRequests: []string{
+				"addbatchstatus 20260531,10:05,8,150;20260531,10:10,18,150;20260531,10:15,28,150;20260531,10:20,38,150",
+			},
+			wantRateLimited: true,
+			wantResumed: []string{
+				"addbatchstatus 20260531,10:25,48,150;20260531,10:30,58,150;20260601,08:05,8,150;20260601,08:10,18,150",
+				"addbatchstatus 20260601,08:15,28,150",
+				"addoutput 20260531 g=58 pp=150 pt=10:00",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := history.Open(filepath.Join(dir, "history"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Append(tt.samples...); err != nil {
+				t.Fatal(err)
+			}
+			statePath := filepath.Join(dir, "state.json")
+			if data, err := json.Marshal(tt.state); err != nil {
+				t.Fatal(err)
+			} else if err := os.WriteFile(statePath, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			fake := &fakePVOutput{limit: tt.limit}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+			client := NewClient(Config{BaseURL: server.URL, APIKey: "key", SystemID: "42", BatchSize: 4})
+
+			run := func() error {
+				u, err := NewUploader(client, store, statePath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return u.Sync(context.Background(), tt.now)
+			}
+
+			err = run()
+			if _, limited := IsRateLimited(err); limited != tt.wantRateLimited || (err != nil && !limited) {
+				t.Fatalf("Sync() error = %v, want rate limited %v", err, tt.wantRateLimited)
+			}
+			if !slices.Equal(fake.requests, tt.wantRequests) {
+				t.Errorf("requests\n%s\nwant\n%s", strings.Join(fake.requests, "\n"), strings.Join(tt.wantRequests, "\n"))
+			}
+
+			// Nothing is sent twice once the state says it was uploaded.
+			fake.limit, fake.requests = 0, nil
+			if err := run(); err != nil {
+				t.Fatalf("second Sync() error = %v", err)
+			}
+			if !slices.Equal(fake.requests, tt.wantResumed) {
+				t.Errorf("second sync requests\n%s\nwant\n%s", strings.Join(fake.requests, "\n"), strings.Join(tt.wantResumed, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package pvoutput
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/internal/history"
+)
+
+// Interval is the status interval configured on PVOutput.
+const Interval = 5 * time.Minute
+
+// maxBackfill is how far back PVOutput accepts live statuses.
+const maxBackfill = 14 * 24 * time.Hour
+
+// Aggregate turns samples into one status per interval. The status carries
+// the highest energy-today reading and the average power within the interval,
+// stamped with the interval's end time.
+func Aggregate(samples []history.Sample, interval time.Duration) []Status {
+	var statuses []Status
+	var bucket time.Time
+	var powerSum, count, energy int
+
+	emit := func() {
+		if count == 0 {
+			return
+		}
+		statuses = append(statuses, Status{
+			Time:     bucket.Add(interval),
+			EnergyWh: energy,
+			PowerW:   int(math.Round(float64(powerSum) / float64(count))),
+		})
+	}
+
+	for _, s := range samples {
+		t := s.Time.Local()
+		start := t.Truncate(interval)
+		if !start.Equal(bucket) {
+			emit()
+			bucket, powerSum, count, energy = start, 0, 0, 0
+		}
+		powerSum += s.P1 + s.P2
+		count++
+		energy = max(energy, int(math.Round((s.E1+s.E2)*1000)))
+	}
+	emit()
+	return statuses
+}
+
+// DailyOutputs summarises samples per local day.
+func DailyOutputs(samples []history.Sample) []Output {
+	var outputs []Output
+	for _, s := range samples {
+		t := s.Time.Local()
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+		if len(outputs) == 0 || !outputs[len(outputs)-1].Date.Equal(day) {
+			outputs = append(outputs, Output{Date: day})
+		}
+		o := &outputs[len(outputs)-1]
+		o.GeneratedWh = max(o.GeneratedWh, int(math.Round((s.E1+s.E2)*1000)))
+		if power := s.P1 + s.P2; power > o.PeakPowerW {
+			o.PeakPowerW = power
+			o.PeakTime = t
+		}
+	}
+	return outputs
+}
+
+// state remembers what has been uploaded so a restart resumes where it left off.
+type state struct {
+	LastStatus time.Time `json:"last_status"`
+	LastOutput time.Time `json:"last_output"`
+}
+
+// Uploader uploads statuses and daily outputs from the history store.
+type Uploader struct {
+	client    *Client
+	store     *history.Store
+	statePath string
+	state     state
+}
+
+func NewUploader(client *Client, store *history.Store, statePath string) (*Uploader, error) {
+	u := &Uploader{client: client, store: store, statePath: statePath}
+	data, err := os.ReadFile(statePath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("read state: %w", err)
+	default:
+		if err := json.Unmarshal(data, &u.state); err != nil {
+			return nil, fmt.Errorf("parse state: %w", err)
+		}
+	}
+	return u, nil
+}
+
+// Sync uploads all completed intervals since the last upload, then the output
+// of every completed day. Intervals missed while offline are backfilled with
+// batch requests, up to PVOutput's 14 day limit.
+func (u *Uploader) Sync(ctx context.Context, now time.Time) error {
+	from := u.state.LastStatus
+	if limit := now.Add(-maxBackfill); from.Before(limit) {
+		from = limit.Truncate(Interval)
+	}
+	// Only whole intervals are uploaded; the current one is still filling up.
+	to := now.Truncate(Interval)
+
+	samples, err := u.store.Range(from, to)
+	if err != nil {
+		return err
+	}
+
+	var pending []Status
+	for _, s := range Aggregate(samples, Interval) {
+		if s.Time.After(u.state.LastStatus) {
+			pending = append(pending, s)
+		}
+	}
+
+	switch len(pending) {
+	case 0:
+	case 1:
+		if err := u.client.AddStatus(ctx, pending[0]); err != nil {
+			return err
+		}
+		u.state.LastStatus = pending[0].Time
+	default:
+		sent, err := u.client.AddBatchStatus(ctx, pending)
+		if sent > 0 {
+			u.state.LastStatus = pending[sent-1].Time
+		}
+		if err != nil {
+			return u.saveAnd(err)
+		}
+	}
+
+	if err := u.syncOutputs(ctx, now); err != nil {
+		return u.saveAnd(err)
+	}
+	return u.saveAnd(nil)
+}
+
+func (u *Uploader) syncOutputs(ctx context.Context, now time.Time) error {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	from := u.state.LastOutput.AddDate(0, 0, 1)
+	if limit := today.Add(-maxBackfill); from.Before(limit) {
+		from = limit
+	}
+	if !from.Before(today) {
+		return nil
+	}
+
+	samples, err := u.store.Range(from, today)
+	if err != nil {
+		return err
+	}
+	for _, o := range DailyOutputs(samples) {
+		if !o.Date.Before(today) || !o.Date.After(u.state.LastOutput) {
+			continue
+		}
+		if err := u.client.AddOutput(ctx, o); err != nil {
+			return err
+		}
+		u.state.LastOutput = o.Date
+	}
+	return nil
+}
+
+func (u *Uploader) saveAnd(err error) error {
+	data, marshalErr := json.Marshal(u.state)
+	if marshalErr != nil {
+		return marshalErr
+	}
+	if writeErr := os.WriteFile(u.statePath, data, 0o644); writeErr != nil && err == nil {
+		return fmt.Errorf("write state: %w", writeErr)
+	}
+	return err
+}
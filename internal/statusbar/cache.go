@@ -0,0 +1,128 @@
+package statusbar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// lockTimeout is how long a bar waits for another one that is polling, and
+// after which a left-over lock file is ignored.
+const lockTimeout = 15 * time.Second
+
+// Cache shares readings between bars, so that several bars and monitors
+// showing the status do not all poll the inverter.
+type Cache struct {
+	Path string
+	// MaxAge is how long a reading is used before the device is polled
+	// again.
+	MaxAge time.Duration
+}
+
+// DefaultCachePath returns the cache file for a device in
+// $XDG_CACHE_HOME/ez1-tui, falling back to ~/.cache.
+func DefaultCachePath(host string, port int) string {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".cache")
+		} else {
+			dir = os.TempDir()
+		}
+	}
+	name := strings.NewReplacer("/", "_", ":", "_", "\\", "_").Replace(fmt.Sprintf("bar-%s-%d.json", host, port))
+	return filepath.Join(dir, "ez1-tui", name)
+}
+
+// Get returns the cached reading if it is recent enough, and otherwise
+// calls fetch and caches its result. While another process is fetching, Get
+// waits for its result instead of polling as well.
+func (c Cache) Get(ctx context.Context, fetch func(context.Context) Status) (Status, error) {
+	cached, err := c.read()
+	if err == nil && time.Since(cached.Time) < c.MaxAge {
+		return cached, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0o755); err != nil {
+		return fetch(ctx), err
+	}
+	unlock, locked := c.lock()
+	if !locked {
+		deadline := time.Now().Add(lockTimeout)
+		for time.Now().Before(deadline) && ctx.Err() == nil {
+			time.Sleep(100 * time.Millisecond)
+			if s, err := c.read(); err == nil && time.Since(s.Time) < c.MaxAge {
+				return s, nil
+			}
+			if unlock, locked = c.lock(); locked {
+				break
+			}
+		}
+		if !locked {
+			return fetch(ctx), nil
+		}
+	}
+	defer unlock()
+
+	s := fetch(ctx)
+	return s, c.write(s)
+}
+
+func (c Cache) read() (Status, error) {
+	var s Status
+	data, err := os.ReadFile(c.Path)
+	if err != nil {
+		return s, err
+	}
+	return s, json.Unmarshal(data, &s)
+}
+
+// write replaces the cache file atomically, so that readers never see a
+// partial file.
+func (c Cache) write(s Status) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.Path), filepath.Base(c.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.Path)
+}
+
+// lock creates the lock file, removing one that was left behind by a
+// process that did not finish.
+func (c Cache) lock() (unlock func(), ok bool) {
+	path := c.Path + ".lock"
+	for range 2 {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, true
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, false
+		}
+		info, err := os.Stat(path)
+		if err != nil || time.Since(info.ModTime()) < lockTimeout {
+			return nil, false
+		}
+		os.Remove(path)
+	}
+	return nil, false
+}
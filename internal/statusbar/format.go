@@ -0,0 +1,133 @@
+package statusbar
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/niclaszll/apsystems-ez1-tui/internal/i18n"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/units"
+)
+
+// DefaultTemplate returns the text shown by every format unless replaced.
+func DefaultTemplate(lang i18n.Printer) string {
+	return `{{if .Offline}}☀ ` + lang.T("bar.offline") + `{{else}}☀ {{power .Power}} · {{energy .Today}}{{if .Alarm}} ⚠{{end}}{{end}}`
+}
+
+// Formats lists the output formats.
+var Formats = []string{"plain", "i3bar", "waybar", "tmux", "polybar"}
+
+// stateColors are used by the formats that support colors. Idle keeps the
+// bar's default color.
+var stateColors = map[State]string{
+	StateOffline:   "#888888",
+	StateAlarm:     "#FF4444",
+	StateProducing: "#44DD44",
+}
+
+// Formatter renders a status as one line in a format.
+type Formatter struct {
+	format string
+	text   *template.Template
+	units  units.Formatter
+	lang   i18n.Printer
+}
+
+// NewFormatter returns a formatter for one of Formats. The text is rendered
+// with tmpl, a text/template executed on a Status, or DefaultTemplate if
+// tmpl is empty. Templates can format values with the functions power,
+// energy, number (value and decimals) and time.
+func NewFormatter(format, tmpl string, u units.Formatter, lang i18n.Printer) (*Formatter, error) {
+	if !slices.Contains(Formats, format) {
+		return nil, fmt.Errorf("unknown format %q (available: %s)", format, strings.Join(Formats, ", "))
+	}
+	if tmpl == "" {
+		tmpl = DefaultTemplate(lang)
+	}
+	t, err := template.New("bar").Funcs(template.FuncMap{
+		"power":  func(watts int) string { return u.Power(float64(watts)) },
+		"energy": u.Energy,
+		"number": u.Number,
+		"time":   u.Time,
+	}).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("template: %w", err)
+	}
+	return &Formatter{format: format, text: t, units: u, lang: lang}, nil
+}
+
+// Format renders s without a trailing newline.
+func (f *Formatter) Format(s Status) (string, error) {
+	var b strings.Builder
+	if err := f.text.Execute(&b, s); err != nil {
+		return "", fmt.Errorf("template: %w", err)
+	}
+	text := strings.TrimSpace(b.String())
+	color := stateColors[s.State()]
+
+	switch f.format {
+	case "i3bar":
+		block := map[string]any{
+			"full_text":  text,
+			"short_text": f.shortText(s),
+			"name":       "ez1",
+			"urgent":     s.Alarm(),
+		}
+		if color != "" {
+			block["color"] = color
+		}
+		return marshal(block)
+	case "waybar":
+		return marshal(map[string]any{
+			"text":    text,
+			"alt":     string(s.State()),
+			"tooltip": f.tooltip(s),
+			"class":   string(s.State()),
+		})
+	case "tmux":
+		if color == "" {
+			return text, nil
+		}
+		return fmt.Sprintf("#[fg=%s]%s#[default]", color, strings.ReplaceAll(text, "#", "##")), nil
+	case "polybar":
+		if color == "" {
+			return text, nil
+		}
+		return fmt.Sprintf("%%{F%s}%s%%{F-}", color, text), nil
+	}
+	return text, nil
+}
+
+func marshal(v any) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+func (f *Formatter) shortText(s Status) string {
+	if s.Offline() {
+		return f.lang.T("bar.offline")
+	}
+	return f.units.Power(float64(s.Power))
+}
+
+func (f *Formatter) tooltip(s Status) string {
+	u, t := f.units, f.lang.T
+	if s.Offline() {
+		return t("bar.offline_since", u.Time(s.Time), s.Error)
+	}
+	lines := []string{
+		t("bar.power", u.Power(float64(s.Power)), u.Power(float64(s.Power1)), u.Power(float64(s.Power2))),
+		t("bar.today", u.Energy(s.Today)),
+		t("bar.lifetime", u.Energy(s.Lifetime)),
+	}
+	if s.Alarm() {
+		lines = append(lines, t("bar.alarms", strings.Join(s.Alarms, ", ")))
+	}
+	if len(s.UnknownAlarms) > 0 {
+		lines = append(lines, t("bar.alarms_unknown", strings.Join(s.UnknownAlarms, ", ")))
+	}
+	lines = append(lines, t("bar.updated", u.Time(s.Time)))
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,106 @@
+// Package statusbar renders a one-line status of the microinverter for
+// status bars like tmux, waybar, i3bar and polybar.
+package statusbar
+
+import (
+	"context"
+	"time"
+
+	"github.com/niclaszll/apsystems-ez1-tui/pkg/apsystems"
+)
+
+// Status is a reading of the device as shown in a bar. It is also the
+// format of the cache file.
+type Status struct {
+	Time     time.Time `json:"time"`
+	Power    int       `json:"power_w"`
+	Power1   int       `json:"p1_w"`
+	Power2   int       `json:"p2_w"`
+	Today    float64   `json:"today_kwh"`
+	Lifetime float64   `json:"lifetime_kwh"`
+	// Alarms lists the active alarms, e.g. "grid_fault".
+	Alarms []string `json:"alarms,omitempty"`
+	// UnknownAlarms lists the alarms the device did not report.
+	UnknownAlarms []string `json:"unknown_alarms,omitempty"`
+	// Error is set if the device could not be read.
+	Error string `json:"error,omitempty"`
+}
+
+// State is the class of a status: offline, alarm, producing or idle.
+type State string
+
+const (
+	StateOffline   State = "offline"
+	StateAlarm     State = "alarm"
+	StateProducing State = "producing"
+	StateIdle      State = "idle"
+)
+
+func (s Status) Offline() bool { return s.Error != "" }
+
+func (s Status) Alarm() bool { return len(s.Alarms) > 0 }
+
+func (s Status) State() State {
+	switch {
+	case s.Offline():
+		return StateOffline
+	case s.Alarm():
+		return StateAlarm
+	case s.Power > 0:
+		return StateProducing
+	default:
+		return StateIdle
+	}
+}
+
+// Device is the part of *apsystems.Client a bar reads from.
+type Device interface {
+	GetStatistics(ctx context.Context) (*apsystems.Statistics, error)
+	GetAlarmInfo(ctx context.Context) (*apsystems.AlarmInfo, error)
+}
+
+// Fetch reads the output data and alarms of the device. Errors are reported
+// in the status, as a bar shows them instead of failing.
+func Fetch(ctx context.Context, device Device) Status {
+	now := time.Now()
+	stats, err := device.GetStatistics(ctx)
+	if err != nil {
+		return Status{Time: now, Error: err.Error()}
+	}
+	s := Status{
+		Time:     now,
+		Power:    stats.TotalPower,
+		Power1:   stats.Power1,
+		Power2:   stats.Power2,
+		Today:    stats.TotalEnergyToday,
+		Lifetime: stats.TotalEnergyLifetime,
+	}
+	// The device answered, so a failed alarm request is not worth marking
+	// it offline; the alarms are just left out.
+	if alarm, err := device.GetAlarmInfo(ctx); err == nil {
+		s.Alarms, s.UnknownAlarms = activeAlarms(alarm)
+	}
+	return s
+}
+
+// activeAlarms returns the raised alarms and those whose state is unknown.
+func activeAlarms(alarm *apsystems.AlarmInfo) (active, unknown []string) {
+	d := alarm.Data
+	for _, a := range []struct {
+		name  string
+		state apsystems.AlarmState
+	}{
+		{"grid_fault", d.Og},
+		{"pv1_short_circuit", d.Isce1},
+		{"pv2_short_circuit", d.Isce2},
+		{"output_error", d.Oe},
+	} {
+		switch {
+		case !a.state.Known():
+			unknown = append(unknown, a.name)
+		case a.state.Active():
+			active = append(active, a.name)
+		}
+	}
+	return active, unknown
+}
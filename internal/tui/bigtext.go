@@ -0,0 +1,69 @@
+package tui
+
+import "strings"
+
+// bigGlyphs is a 3×5 block font for numbers; '#' cells are filled.
+var bigGlyphs = map[rune][5]string{
+	'0': {"###", "# #", "# #", "# #", "###"},
+	'1': {" # ", "## ", " # ", " # ", "###"},
+	'2': {"###", "  #", "###", "#  ", "###"},
+	'3': {"###", "  #", "###", "  #", "###"},
+	'4': {"# #", "# #", "###", "  #", "  #"},
+	'5': {"###", "#  ", "###", "  #", "###"},
+	'6': {"###", "#  ", "###", "# #", "###"},
+	'7': {"###", "  #", "  #", "  #", "  #"},
+	'8': {"###", "# #", "###", "# #", "###"},
+	'9': {"###", "# #", "###", "  #", "###"},
+	'.': {" ", " ", " ", " ", "#"},
+	',': {" ", " ", " ", "#", "#"},
+	'-': {"   ", "   ", "###", "   ", "   "},
+	' ': {" ", " ", " ", " ", " "},
+}
+
+// bigScale is the size of a font cell in terminal cells. Cells are about
+// twice as high as wide, so twice as many columns as rows keep it square.
+type bigScale struct {
+	w, h int
+}
+
+var bigScales = []bigScale{{6, 3}, {4, 2}, {2, 1}, {1, 1}}
+
+// bigSize returns the size of s rendered at scale.
+func bigSize(s string, scale bigScale) (width, height int) {
+	for i, r := range s {
+		if i > 0 {
+			width += scale.w
+		}
+		width += len(bigGlyphs[r][0]) * scale.w
+	}
+	return width, 5 * scale.h
+}
+
+// bigText renders s in the block font. Characters without a glyph are
+// skipped.
+func bigText(s string, scale bigScale) string {
+	var lines []string
+	for row := range 5 {
+		var b strings.Builder
+		for i, r := range s {
+			glyph, ok := bigGlyphs[r]
+			if !ok {
+				continue
+			}
+			if i > 0 {
+				b.WriteString(strings.Repeat(" ", scale.w))
+			}
+			for _, cell := range glyph[row] {
+				fill := " "
+				if cell == '#' {
+					fill = "█"
+				}
+				b.WriteString(strings.Repeat(fill, scale.w))
+			}
+		}
+		for range scale.h {
+			lines = append(lines, b.String())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
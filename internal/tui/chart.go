@@ -0,0 +1,64 @@
+package tui
+
+import (
+	"strings"
+	"time"
+)
+
+// chartSamples is the number of power readings kept for the chart, an hour
+// at the default output interval.
+const chartSamples = 360
+
+type powerSample struct {
+	time  time.Time
+	watts int
+}
+
+// recordPower appends a reading to the chart history. The slice is copied
+// on write as Model is passed by value.
+func (m *Model) recordPower(at time.Time, watts int) {
+	history := m.powerHistory
+	if len(history) >= chartSamples {
+		history = history[len(history)-chartSamples+1:]
+	}
+	m.powerHistory = append(append([]powerSample(nil), history...), powerSample{at, watts})
+}
+
+var chartBlocks = []rune(" ▁▂▃▄▅▆▇█")
+
+// renderChart draws the most recent power readings as bars, one column per
+// reading, in width × height cells including a caption line.
+func (m Model) renderChart(width, height int) string {
+	if len(m.powerHistory) == 0 {
+		return "\n" + m.lang.T("chart.waiting")
+	}
+	if width < 1 || height < 2 {
+		return ""
+	}
+	samples := m.powerHistory[max(len(m.powerHistory)-width, 0):]
+	peak := 1
+	for _, s := range samples {
+		peak = max(peak, s.watts)
+	}
+
+	rows := height - 1
+	lines := make([]string, 0, height)
+	for row := rows - 1; row >= 0; row-- {
+		var b strings.Builder
+		for _, s := range samples {
+			eighths := s.watts * rows * 8 / peak
+			switch fill := eighths - row*8; {
+			case fill >= 8:
+				b.WriteRune(chartBlocks[8])
+			case fill > 0:
+				b.WriteRune(chartBlocks[fill])
+			default:
+				b.WriteRune(' ')
+			}
+		}
+		lines = append(lines, m.styles.power.Render(b.String()))
+	}
+	first := samples[0].time
+	lines = append(lines, m.styles.hint.Render(m.lang.T("chart.caption", m.units.Power(float64(peak)), m.units.Clock(first))))
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,136 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/niclaszll/apsystems-ez1-tui/pkg/apsystems"
+)
+
+// healthReporter is implemented by devices that collect connection
+// statistics, such as *apsystems.Client.
+type healthReporter interface {
+	Health() apsystems.Health
+}
+
+func (m Model) health() (apsystems.Health, bool) {
+	h, ok := m.client.(healthReporter)
+	if !ok {
+		return apsystems.Health{}, false
+	}
+	return h.Health(), true
+}
+
+func (m Model) renderHealthBadge(h apsystems.Health) string {
+	style := lipgloss.NewStyle().Padding(0, 1).Bold(true)
+	switch h.Status() {
+	case apsystems.StatusOnline:
+		return style.Foreground(m.theme.Good).Render(fmt.Sprintf("● %s %s", m.lang.T("connection.online"), formatLatency(h.Latency())))
+	case apsystems.StatusDegraded:
+		return style.Foreground(m.theme.Warning).Render("◐ " + m.lang.T("connection.degraded"))
+	case apsystems.StatusOffline:
+		return style.Foreground(m.theme.Bad).Render(fmt.Sprintf("○ %s (%s)", m.lang.T("connection.offline"), m.lang.T("failure."+h.LastFailureKind.String())))
+	default:
+		return style.Foreground(m.theme.Muted).Render("○ " + m.lang.T("connection.connecting"))
+	}
+}
+
+func formatLatency(d time.Duration) string {
+	if d == 0 {
+		return "–"
+	}
+	return fmt.Sprintf("%d ms", d.Milliseconds())
+}
+
+func (m Model) formatAgo(t time.Time) string {
+	if t.IsZero() {
+		return m.lang.T("diagnostics.never")
+	}
+	return m.lang.T("diagnostics.ago", time.Since(t).Round(time.Second))
+}
+
+// diagnosis is the translated counterpart of apsystems.Health.Diagnosis.
+func (m Model) diagnosis(h apsystems.Health) string {
+	switch p := h.Problem(); p {
+	case apsystems.ProblemNone:
+		return ""
+	case apsystems.ProblemSlow:
+		e, _ := h.Slowest()
+		return m.lang.T("diagnosis.slow", e.P90.Round(time.Millisecond), e.Endpoint)
+	default:
+		return m.lang.T("diagnosis." + p.String())
+	}
+}
+
+func (m Model) renderDiagnostics() string {
+	h, ok := m.health()
+	if !ok {
+		return "\n" + m.lang.T("diagnostics.unavailable")
+	}
+
+	t := m.lang.T
+	labelStyle := m.labelStyle(t("diagnostics.connection"), t("diagnostics.failures"), t("diagnostics.last_success"), t("diagnostics.polling"))
+	valueStyle := m.styles.value
+	headerStyle := m.styles.header
+	errorStyle := m.styles.warning
+
+	lines := []string{
+		"",
+		labelStyle.Render(t("diagnostics.connection")) + valueStyle.Render(t("connection."+h.Status().String())),
+		labelStyle.Render(t("diagnostics.failures")) + valueStyle.Render(fmt.Sprintf("%d", h.ConsecutiveFailures)),
+		labelStyle.Render(t("diagnostics.last_success")) + valueStyle.Render(m.formatAgo(h.LastSuccess)),
+		labelStyle.Render(t("diagnostics.polling")) + valueStyle.Render(m.pollState()),
+	}
+	if hint := m.diagnosis(h); hint != "" {
+		lines = append(lines, "", errorStyle.Render("⚠ "+hint))
+	}
+
+	if len(h.Endpoints) == 0 {
+		return lipgloss.JoinVertical(lipgloss.Left, lines...)
+	}
+
+	// Columns are as wide as their longest cell in any language; the
+	// endpoint and the last column are left-aligned, numbers right-aligned.
+	rows := [][]string{{t("diagnostics.endpoint"), t("diagnostics.success"), "p50", "p90", "p99", t("diagnostics.fails"), t("diagnostics.last_success_column")}}
+	for _, e := range h.Endpoints {
+		rows = append(rows, []string{
+			e.Endpoint,
+			fmt.Sprintf("%.0f%%", e.SuccessRate()*100),
+			formatLatency(e.P50),
+			formatLatency(e.P90),
+			formatLatency(e.P99),
+			fmt.Sprintf("%d", e.ConsecutiveFailures),
+			m.formatAgo(e.LastSuccess),
+		})
+	}
+	widths := make([]int, len(rows[0]))
+	for _, r := range rows {
+		for i, cell := range r {
+			widths[i] = max(widths[i], lipgloss.Width(cell))
+		}
+	}
+	formatRow := func(r []string) string {
+		cells := make([]string, len(r))
+		for i, cell := range r {
+			pad := strings.Repeat(" ", widths[i]-lipgloss.Width(cell))
+			if i == 0 || i == len(r)-1 {
+				cells[i] = cell + pad
+			} else {
+				cells[i] = pad + cell
+			}
+		}
+		return strings.Join(cells, "  ")
+	}
+
+	lines = append(lines, "", headerStyle.Render(formatRow(rows[0])))
+	for i, e := range h.Endpoints {
+		lines = append(lines, formatRow(rows[i+1]))
+		if e.ConsecutiveFailures > 0 {
+			lines = append(lines, errorStyle.Render(fmt.Sprintf("  %s: %s", t("failure."+e.LastFailureKind.String()), e.LastError)))
+		}
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
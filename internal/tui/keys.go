@@ -0,0 +1,162 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// KeyMap holds the key bindings of the TUI.
+type KeyMap struct {
+	Help        key.Binding
+	Quit        key.Binding
+	Refresh     key.Binding
+	NextView    key.Binding
+	PrevView    key.Binding
+	JumpView    key.Binding
+	PowerOn     key.Binding
+	PowerOff    key.Binding
+	IncreasePwr key.Binding
+	DecreasePwr key.Binding
+	Confirm     key.Binding
+	Cancel      key.Binding
+	Palette     key.Binding
+}
+
+func (k KeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Help, k.Quit, k.NextView, k.Refresh, k.Palette}
+}
+
+func (k KeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.NextView, k.PrevView, k.JumpView, k.Refresh},
+		{k.Help, k.Quit, k.Palette},
+		{k.PowerOn, k.PowerOff},
+		{k.IncreasePwr, k.DecreasePwr},
+		{k.Confirm, k.Cancel},
+	}
+}
+
+// keyAction is a configurable binding. The first key is shown in the help.
+type keyAction struct {
+	name     string
+	help     string
+	defaults []string
+	binding  func(*KeyMap) *key.Binding
+}
+
+var keyActions = []keyAction{
+	{"help", "toggle help", []string{"?"}, func(k *KeyMap) *key.Binding { return &k.Help }},
+	{"quit", "quit", []string{"q", "ctrl+c"}, func(k *KeyMap) *key.Binding { return &k.Quit }},
+	{"refresh", "refresh", []string{"r"}, func(k *KeyMap) *key.Binding { return &k.Refresh }},
+	{"next_view", "next view", []string{"tab", "right", "l"}, func(k *KeyMap) *key.Binding { return &k.NextView }},
+	{"prev_view", "previous view", []string{"shift+tab", "left", "h"}, func(k *KeyMap) *key.Binding { return &k.PrevView }},
+	{"power_on", "power on", []string{"o"}, func(k *KeyMap) *key.Binding { return &k.PowerOn }},
+	{"power_off", "power off", []string{"f"}, func(k *KeyMap) *key.Binding { return &k.PowerOff }},
+	{"increase_limit", "increase power", []string{"+", "="}, func(k *KeyMap) *key.Binding { return &k.IncreasePwr }},
+	{"decrease_limit", "decrease power", []string{"-", "_"}, func(k *KeyMap) *key.Binding { return &k.DecreasePwr }},
+	{"confirm", "confirm", []string{"enter"}, func(k *KeyMap) *key.Binding { return &k.Confirm }},
+	{"cancel", "cancel", []string{"esc"}, func(k *KeyMap) *key.Binding { return &k.Cancel }},
+	{"palette", "commands", []string{":", "ctrl+p"}, func(k *KeyMap) *key.Binding { return &k.Palette }},
+}
+
+// helpKeys returns the key bindings with the help translated into the
+// language of the TUI.
+func (m Model) helpKeys() KeyMap {
+	k := m.keys
+	for _, a := range keyActions {
+		b := a.binding(&k)
+		b.SetHelp(b.Help().Key, m.lang.T("key."+a.name))
+	}
+	k.JumpView.SetHelp(k.JumpView.Help().Key, m.lang.T("key.jump_view"))
+	return k
+}
+
+// KeyActions lists the names of the configurable bindings.
+func KeyActions() []string {
+	var names []string
+	for _, a := range keyActions {
+		names = append(names, a.name)
+	}
+	return names
+}
+
+// jumpKeys are the digits that select a view directly.
+func jumpKeys() []string {
+	var keys []string
+	for i := range numViews {
+		keys = append(keys, strconv.Itoa(i+1))
+	}
+	return keys
+}
+
+// keySymbols are shown in the help instead of key names.
+var keySymbols = map[string]string{
+	"up":        "↑",
+	"down":      "↓",
+	"left":      "←",
+	"right":     "→",
+	"shift+tab": "⇧tab",
+	"enter":     "↵",
+}
+
+func helpKey(keys []string) string {
+	k := keys[0]
+	if s, ok := keySymbols[k]; ok {
+		return s
+	}
+	return k
+}
+
+// DefaultKeys returns the default key bindings.
+func DefaultKeys() KeyMap {
+	keys, _ := NewKeyMap(nil)
+	return keys
+}
+
+// NewKeyMap returns the default key bindings with the keys of the given
+// actions replaced. An empty list disables an action. Keys bound to more
+// than one action are rejected.
+func NewKeyMap(bindings map[string][]string) (KeyMap, error) {
+	var k KeyMap
+	owner := make(map[string]string)
+	for _, key := range jumpKeys() {
+		owner[key] = "view selection"
+	}
+
+	for name := range bindings {
+		if !slices.ContainsFunc(keyActions, func(a keyAction) bool { return a.name == name }) {
+			return k, fmt.Errorf("unknown key action %q (available: %s)", name, strings.Join(KeyActions(), ", "))
+		}
+	}
+
+	for _, a := range keyActions {
+		keys, ok := bindings[a.name]
+		if !ok {
+			keys = a.defaults
+		}
+		if len(keys) == 0 {
+			if a.name == "quit" {
+				return k, fmt.Errorf("quit must have a key")
+			}
+			*a.binding(&k) = key.NewBinding(key.WithDisabled())
+			continue
+		}
+		for _, key := range keys {
+			if other, taken := owner[key]; taken {
+				return k, fmt.Errorf("key %q is bound to both %s and %s", key, other, a.name)
+			}
+			owner[key] = a.name
+		}
+		*a.binding(&k) = key.NewBinding(key.WithKeys(keys...), key.WithHelp(helpKey(keys), a.help))
+	}
+
+	k.JumpView = key.NewBinding(
+		key.WithKeys(jumpKeys()...),
+		key.WithHelp(fmt.Sprintf("1-%d", numViews), "jump to view"),
+	)
+	return k, nil
+}
@@ -0,0 +1,212 @@
+package tui
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/niclaszll/apsystems-ez1-tui/pkg/apsystems"
+)
+
+// Kiosk configures the wall display mode: large numbers, pages that rotate
+// on their own and no controls except quit.
+type Kiosk struct {
+	// Rotate is how long each page is shown, DefaultKioskRotate if zero.
+	Rotate time.Duration
+	// NightStart and NightEnd are the times of day, as offsets from
+	// midnight, between which the display is dimmed and stays on the main
+	// page. Equal values disable this.
+	NightStart, NightEnd time.Duration
+}
+
+const DefaultKioskRotate = 15 * time.Second
+
+// kioskMaxBackoff keeps retries frequent so that the display recovers soon
+// after the device is back.
+const kioskMaxBackoff = time.Minute
+
+// The content is moved by up to this many cells, one step a minute, so that
+// no pixel shows the same thing all day.
+const (
+	kioskShiftX = 2
+	kioskShiftY = 1
+)
+
+var kioskShifts = []struct{ x, y int }{
+	{0, 0}, {1, 0}, {2, 0}, {2, 1}, {1, 1}, {0, 1},
+}
+
+type kioskPage int
+
+const (
+	kioskPower kioskPage = iota
+	kioskChart
+	kioskStatus
+
+	numKioskPages = iota
+)
+
+type kioskTickMsg time.Time
+
+// WithKiosk enables the wall display mode.
+func WithKiosk(k Kiosk) Option {
+	return func(m *Model) {
+		if k.Rotate <= 0 {
+			k.Rotate = DefaultKioskRotate
+		}
+		m.kiosk = &k
+		m.now = time.Now()
+		m.watchOpts = append(m.watchOpts, apsystems.WithBackoff(apsystems.DefaultMinBackoff, kioskMaxBackoff))
+	}
+}
+
+func kioskTick() tea.Cmd {
+	return tea.Every(time.Second, func(t time.Time) tea.Msg {
+		return kioskTickMsg(t)
+	})
+}
+
+// night reports whether t lies in the dimmed hours.
+func (k Kiosk) night(t time.Time) bool {
+	if k.NightStart == k.NightEnd {
+		return false
+	}
+	y, mo, d := t.Date()
+	tod := t.Sub(time.Date(y, mo, d, 0, 0, 0, 0, t.Location()))
+	if k.NightStart < k.NightEnd {
+		return tod >= k.NightStart && tod < k.NightEnd
+	}
+	return tod >= k.NightStart || tod < k.NightEnd
+}
+
+// dimmed reports whether the kiosk is in night mode, either by the clock or
+// because polling sleeps overnight.
+func (m Model) dimmed() bool {
+	return m.kiosk.night(m.now) || m.sleeping()
+}
+
+// kioskPage returns the page to show; it is derived from the clock so that
+// pages change on their own.
+func (m Model) kioskPage() kioskPage {
+	if m.dimmed() {
+		return kioskPower
+	}
+	return kioskPage(m.now.UnixNano() / int64(m.kiosk.Rotate) % numKioskPages)
+}
+
+// dimTheme draws everything in the muted color of t.
+func dimTheme(t Theme) Theme {
+	t.Text, t.Accent, t.AccentText, t.Good, t.Warning, t.Bad = t.Muted, t.Muted, t.Muted, t.Muted, t.Muted, t.Muted
+	return t
+}
+
+func (m Model) renderKiosk() string {
+	if m.dimmed() {
+		m.styles = newStyles(dimTheme(m.theme))
+		m.styles.power = m.styles.power.Faint(true)
+		m.styles.value = m.styles.value.Faint(true)
+	}
+
+	width, height := m.width-kioskShiftX, m.height-kioskShiftY
+	status := m.renderKioskStatus()
+	height -= lipgloss.Height(status)
+
+	var content string
+	switch m.kioskPage() {
+	case kioskPower:
+		content = m.renderKioskPower(width, height)
+	case kioskChart:
+		content = lipgloss.JoinVertical(lipgloss.Left,
+			m.styles.value.Render(m.lang.T("chart.title")),
+			m.renderChart(width, height-1),
+		)
+	case kioskStatus:
+		var extra []labelValue
+		if m.powerStatus != nil {
+			extra = append(extra, labelValue{m.lang.T("dashboard.power_status"), m.powerState(m.powerStatus.Data.Status)})
+		}
+		if m.powerLimit != nil {
+			extra = append(extra, labelValue{m.lang.T("dashboard.power_limit"), m.units.Power(float64(m.powerLimit.Data.MaxPower))})
+		}
+		content = m.renderAlarms(extra...)
+	}
+
+	view := lipgloss.JoinVertical(lipgloss.Center,
+		lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, fit(content, width, height)),
+		lipgloss.PlaceHorizontal(width, lipgloss.Center, status),
+	)
+	shift := kioskShifts[m.now.Unix()/60%int64(len(kioskShifts))]
+	pad := strings.Repeat(" ", shift.x)
+	view = strings.Repeat("\n", shift.y) + pad + strings.ReplaceAll(view, "\n", "\n"+pad)
+	return fit(m.zones.scan(view), m.width, m.height)
+}
+
+// renderKioskPower shows the current power and today's energy in the
+// largest font that fits.
+func (m Model) renderKioskPower(width, height int) string {
+	if m.stats == nil {
+		if m.sleeping() {
+			return m.styles.hint.Render(m.lang.T("kiosk.offline_overnight", m.pollState()))
+		}
+		return m.styles.hint.Render(m.spinner.View() + " " + m.lang.T("kiosk.connecting"))
+	}
+
+	watts, powerUnit := m.units.PowerParts(float64(m.stats.TotalPower))
+	energy, energyUnit := m.units.EnergyParts(m.stats.TotalEnergyToday)
+	wattsLabel := m.styles.label.Render(m.lang.T("kiosk.now", powerUnit))
+	energyLabel := m.styles.label.Render(m.lang.T("kiosk.today", energyUnit))
+
+	for _, scale := range bigScales {
+		ww, wh := bigSize(watts, scale)
+		ew, eh := bigSize(energy, scale)
+		if max(ww, ew) <= width && wh+eh+3 <= height {
+			return lipgloss.JoinVertical(lipgloss.Center,
+				m.styles.power.Render(bigText(watts, scale)),
+				wattsLabel,
+				"",
+				m.styles.value.Render(bigText(energy, scale)),
+				energyLabel,
+			)
+		}
+	}
+	// Not enough room for both: only the power is shown large.
+	energyLine := m.styles.value.Render(energy) + " " + energyLabel
+	if ww, wh := bigSize(watts, bigScale{1, 1}); ww <= width && wh+2 <= height {
+		return lipgloss.JoinVertical(lipgloss.Center,
+			lipgloss.JoinHorizontal(lipgloss.Bottom, m.styles.power.Render(bigText(watts, bigScale{1, 1})), " ", wattsLabel),
+			"",
+			energyLine,
+		)
+	}
+	return m.styles.power.Render(watts) + " " + wattsLabel + "\n" + energyLine
+}
+
+// renderKioskStatus is the bottom line: page indicator, clock and, if the
+// device cannot be reached, when the next attempt is made. Errors never
+// need to be acknowledged; the last readings stay on screen until polling
+// recovers.
+func (m Model) renderKioskStatus() string {
+	var dots []string
+	for p := range kioskPage(numKioskPages) {
+		if p == m.kioskPage() {
+			dots = append(dots, "●")
+		} else {
+			dots = append(dots, "○")
+		}
+	}
+	parts := []string{m.styles.hint.Render(strings.Join(dots, " ")), m.styles.hint.Render(m.units.Clock(m.now))}
+
+	switch state := m.watcher.State(); {
+	case state.Mode == apsystems.PollBackoff:
+		parts = append(parts, m.styles.warning.Render("⚠ "+m.pollState()))
+	case m.err != nil && !m.sleeping():
+		parts = append(parts, m.styles.warning.Render("⚠ "+m.lang.T("kiosk.retrying")))
+	case m.stats != nil:
+		parts = append(parts, m.styles.hint.Render(m.lang.T("kiosk.updated", m.units.Time(m.stats.LastUpdate))))
+	}
+	if m.warning != "" {
+		parts = append(parts, m.styles.warning.Render("⚠ "+m.warning))
+	}
+	return strings.Join(parts, m.styles.hint.Render("  ·  "))
+}
@@ -0,0 +1,107 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Layout controls when the dashboard shows the dashboard, chart, alarms and
+// power control as a grid of panels instead of one view at a time.
+type Layout struct {
+	// MinWidth and MinHeight are the terminal size from which on the grid
+	// is shown. Zero selects the default, a negative value disables the
+	// grid.
+	MinWidth, MinHeight int
+}
+
+// Default minimum terminal size of the grid.
+const (
+	DefaultGridMinWidth  = 120
+	DefaultGridMinHeight = 32
+)
+
+// Below this size only a hint to enlarge the terminal is shown.
+const (
+	minWidth  = 30
+	minHeight = 8
+)
+
+// WithLayout sets when the grid layout is used.
+func WithLayout(layout Layout) Option {
+	return func(m *Model) {
+		m.layout = layout
+	}
+}
+
+// gridLayout reports whether the dashboard is shown as a grid of panels.
+func (m Model) gridLayout() bool {
+	minW, minH := m.layout.MinWidth, m.layout.MinHeight
+	if minW == 0 {
+		minW = DefaultGridMinWidth
+	}
+	if minH == 0 {
+		minH = DefaultGridMinHeight
+	}
+	return minW > 0 && minH > 0 && m.width >= minW && m.height >= minH
+}
+
+// powerControlVisible reports whether the power control buttons are active,
+// either in the Power Control view or its panel.
+func (m Model) powerControlVisible() bool {
+	return m.currentView == ViewPowerControl || (m.currentView == ViewDashboard && m.gridLayout())
+}
+
+// powerKeysActive reports whether the power and limit keys write to the
+// device. They only do in the Power Control view, so that a key pressed on
+// the dashboard never switches the inverter or changes its limit.
+func (m Model) powerKeysActive() bool {
+	return m.currentView == ViewPowerControl
+}
+
+// fit clips s to the given size so that it never wraps.
+func fit(s string, width, height int) string {
+	return lipgloss.NewStyle().MaxWidth(max(width, 0)).MaxHeight(max(height, 0)).Render(s)
+}
+
+// renderGrid renders the dashboard, chart, alarms and power control panels
+// in two rows filling width × height.
+func (m Model) renderGrid(width, height int) string {
+	leftW := width / 2
+	rightW := width - leftW
+	topH := height / 2
+	bottomH := height - topH
+
+	top := lipgloss.JoinHorizontal(lipgloss.Top,
+		m.renderPanel(m.viewName(ViewDashboard), m.renderDashboard(), leftW, topH),
+		m.renderPanel(m.lang.T("chart.title"), m.renderChart(rightW-4, topH-3), rightW, topH),
+	)
+	bottom := lipgloss.JoinHorizontal(lipgloss.Top,
+		m.renderPanel(m.viewName(ViewAlarms), m.renderAlarms(), leftW, bottomH),
+		m.renderPanel(m.viewName(ViewPowerControl), m.renderPowerControl(), rightW, bottomH),
+	)
+	return lipgloss.JoinVertical(lipgloss.Left, top, bottom)
+}
+
+// renderPanel draws content with a title in a border of exactly width ×
+// height cells, clipping what does not fit.
+func (m Model) renderPanel(title, content string, width, height int) string {
+	innerW, innerH := width-4, height-2
+	body := lipgloss.JoinVertical(lipgloss.Left,
+		m.styles.value.Render(title),
+		strings.TrimPrefix(content, "\n"),
+	)
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(m.theme.Muted).
+		Padding(0, 1).
+		Width(width - 2).
+		Height(innerH).
+		Render(fit(body, innerW, innerH))
+}
+
+// renderTooSmall is shown instead of the UI on tiny terminals.
+func (m Model) renderTooSmall() string {
+	return fit(m.lang.T("layout.too_small")+fmt.Sprintf("\n%d×%d < %d×%d", m.width, m.height, minWidth, minHeight), m.width, m.height)
+}
@@ -0,0 +1,106 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Limits of the power limit and the step of the +/- keys, buttons and the
+// scroll wheel.
+const (
+	minLimit  = 30
+	maxLimit  = 800
+	limitStep = 50
+)
+
+func tabZone(v View) string {
+	return fmt.Sprintf("tab:%d", v)
+}
+
+// button renders a clickable button registered as zone.
+func (m Model) button(zone, label string) string {
+	style := m.styles.button
+	if m.hover == zone {
+		style = m.styles.buttonHover
+	}
+	return m.zones.mark(zone, style.Render(label))
+}
+
+func (m Model) renderTooltip(text string) string {
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(m.theme.Muted).
+		Foreground(m.theme.Text).
+		Padding(0, 1).
+		Width(60).
+		Render(text)
+}
+
+// stepLimit changes the power limit by delta watts within the allowed range.
+func (m Model) stepLimit(delta int) tea.Cmd {
+	if !m.powerControlVisible() || m.powerLimit == nil {
+		return nil
+	}
+	watts := int(m.powerLimit.Data.MaxPower) + delta
+	if watts < minLimit || watts > maxLimit {
+		return nil
+	}
+	return m.setMaxPower(watts)
+}
+
+func (m Model) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	zone := m.zones.at(msg.X, msg.Y)
+	m.hover = zone
+	if msg.Action != tea.MouseActionPress {
+		return m, nil
+	}
+
+	switch msg.Button {
+	case tea.MouseButtonWheelUp, tea.MouseButtonWheelDown:
+		// Scrolling only proposes a limit; it is applied after
+		// confirmation.
+		if m.powerControlVisible() && m.powerLimit != nil {
+			watts := m.pendingLimit
+			if watts == 0 {
+				watts = int(m.powerLimit.Data.MaxPower)
+			}
+			if msg.Button == tea.MouseButtonWheelUp {
+				watts += limitStep
+			} else {
+				watts -= limitStep
+			}
+			m.pendingLimit = min(max(watts, minLimit), maxLimit)
+		}
+		return m, nil
+	case tea.MouseButtonLeft:
+	default:
+		return m, nil
+	}
+
+	if v, ok := strings.CutPrefix(zone, "tab:"); ok {
+		n, _ := strconv.Atoi(v)
+		m.currentView = View(n)
+		return m, nil
+	}
+	switch zone {
+	case "power-on":
+		return m, m.setPowerStatus("ON")
+	case "power-off":
+		return m, m.setPowerStatus("OFF")
+	case "limit-up":
+		return m, m.stepLimit(limitStep)
+	case "limit-down":
+		return m, m.stepLimit(-limitStep)
+	case "limit-apply":
+		watts := m.pendingLimit
+		m.pendingLimit = 0
+		return m, m.setMaxPower(watts)
+	case "limit-cancel":
+		m.pendingLimit = 0
+	}
+	return m, nil
+}
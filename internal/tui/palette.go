@@ -0,0 +1,457 @@
+package tui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/emissions"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/history"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/i18n"
+)
+
+// paletteCommand is an action of the command palette. Commands run the same
+// code as the keys and buttons for the action.
+type paletteCommand struct {
+	name string
+	// arg is the prompt for the argument, or "" if there is none.
+	arg string
+	// suggest returns completions for the argument; the first is used
+	// for tab.
+	suggest func(m Model) []string
+	run     func(m Model, arg string) (Model, tea.Cmd, error)
+}
+
+// paletteEntry is a command run from the palette, with its argument.
+type paletteEntry struct {
+	command string
+	arg     string
+}
+
+func (e paletteEntry) String() string {
+	return strings.TrimSpace(e.command + " " + e.arg)
+}
+
+// paletteHistorySize is the number of recent commands shown when the
+// palette opens.
+const paletteHistorySize = 5
+
+type noticeMsg string
+
+func (m Model) paletteCommands() []paletteCommand {
+	t := m.lang.T
+	var commands []paletteCommand
+	for v := range View(numViews) {
+		commands = append(commands, paletteCommand{
+			name: t("palette.go_to", m.viewName(v)),
+			run: func(m Model, _ string) (Model, tea.Cmd, error) {
+				m.currentView = v
+				return m, nil, nil
+			},
+		})
+	}
+	return append(commands,
+		paletteCommand{
+			name: t("palette.set_limit"),
+			arg:  t("palette.set_limit.arg", minLimit, maxLimit),
+			suggest: func(m Model) []string {
+				if m.powerLimit == nil {
+					return nil
+				}
+				return []string{strconv.Itoa(int(m.powerLimit.Data.MaxPower))}
+			},
+			run: func(m Model, arg string) (Model, tea.Cmd, error) {
+				watts, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(arg), "W"))
+				if err != nil || watts < minLimit || watts > maxLimit {
+					return m, nil, errors.New(t("palette.set_limit.invalid", minLimit, maxLimit))
+				}
+				return m, m.setMaxPower(watts), nil
+			},
+		},
+		paletteCommand{
+			name: t("palette.power_on"),
+			run: func(m Model, _ string) (Model, tea.Cmd, error) {
+				return m, m.setPowerStatus("ON"), nil
+			},
+		},
+		paletteCommand{
+			name: t("palette.power_off"),
+			run: func(m Model, _ string) (Model, tea.Cmd, error) {
+				return m, m.setPowerStatus("OFF"), nil
+			},
+		},
+		paletteCommand{
+			name: t("palette.refresh"),
+			run: func(m Model, _ string) (Model, tea.Cmd, error) {
+				m.watcher.Refresh()
+				return m, nil, nil
+			},
+		},
+		paletteCommand{
+			name: t("palette.export"),
+			arg:  t("palette.export.arg"),
+			suggest: func(m Model) []string {
+				return []string{fmt.Sprintf("ez1-%s.csv", time.Now().Format(time.DateOnly))}
+			},
+			run: func(m Model, arg string) (Model, tea.Cmd, error) {
+				if m.history == nil {
+					return m, nil, errors.New(t("palette.export.disabled"))
+				}
+				format, err := history.ParseFormat(strings.TrimPrefix(filepath.Ext(arg), "."))
+				if err != nil {
+					return m, nil, err
+				}
+				return m, exportToday(m.history, arg, format, m.lang), nil
+			},
+		},
+		paletteCommand{
+			name: t("palette.switch"),
+			arg:  t("palette.switch.arg"),
+			suggest: func(m Model) []string {
+				names := make([]string, 0, len(m.devices))
+				for name := range m.devices {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				return names
+			},
+			run: func(m Model, arg string) (Model, tea.Cmd, error) {
+				return m.switchDevice(strings.TrimSpace(arg))
+			},
+		},
+		paletteCommand{
+			name: t("palette.theme"),
+			run: func(m Model, _ string) (Model, tea.Cmd, error) {
+				i := slices.IndexFunc(themes, func(t Theme) bool { return t.Name == m.theme.Name })
+				next := themes[(i+1)%len(themes)]
+				m.applyTheme(next)
+				return m, notice(t("palette.theme.done", next.Name)), nil
+			},
+		},
+		paletteCommand{
+			name: t("palette.help"),
+			run: func(m Model, _ string) (Model, tea.Cmd, error) {
+				m.showHelp = !m.showHelp
+				return m, nil, nil
+			},
+		},
+		paletteCommand{
+			name: t("palette.quit"),
+			run: func(m Model, _ string) (Model, tea.Cmd, error) {
+				m.stop()
+				return m, tea.Quit, nil
+			},
+		},
+	)
+}
+
+func notice(text string) tea.Cmd {
+	return func() tea.Msg { return noticeMsg(text) }
+}
+
+// WithDevices enables the switch device command. connect opens a device
+// by address; devices maps names offered for completion to addresses.
+func WithDevices(devices map[string]string, connect func(addr string) (Device, error)) Option {
+	return func(m *Model) {
+		m.devices = devices
+		m.connect = connect
+	}
+}
+
+// switchDevice replaces the polled device and discards all readings of the
+// previous one. History recording stops, as the store holds one device.
+func (m Model) switchDevice(name string) (Model, tea.Cmd, error) {
+	if m.connect == nil {
+		return m, nil, errors.New(m.lang.T("palette.switch.unavailable"))
+	}
+	if name == "" {
+		return m, nil, errors.New(m.lang.T("palette.switch.empty"))
+	}
+	addr := name
+	if a, ok := m.devices[name]; ok {
+		addr = a
+	}
+	device, err := m.connect(addr)
+	if err != nil {
+		return m, nil, err
+	}
+
+	m.stop()
+	m.client = device
+	m.startWatcher()
+	m.stats, m.deviceInfo, m.alarmInfo, m.powerStatus, m.powerLimit = nil, nil, nil, nil, nil
+	m.avoided, m.powerHistory, m.pendingLimit, m.err = nil, nil, 0, nil
+	m.loading = true
+	m.history = nil
+	if m.emissions != nil {
+		m.emissions = emissions.NewEstimator(m.emissions.Intensity())
+	}
+	return m, tea.Batch(m.runWatcher(), waitForUpdate(m.updates), notice(m.lang.T("palette.switch.done", name))), nil
+}
+
+// exportToday writes today's recorded samples to path.
+func exportToday(store *history.Store, path string, format history.Format, lang i18n.Printer) tea.Cmd {
+	return func() tea.Msg {
+		now := time.Now()
+		y, mo, d := now.Date()
+		samples, err := store.Range(time.Date(y, mo, d, 0, 0, 0, 0, now.Location()), now)
+		if err != nil {
+			return errMsg(fmt.Errorf("export: %w", err))
+		}
+		file, err := os.Create(path)
+		if err != nil {
+			return errMsg(fmt.Errorf("export: %w", err))
+		}
+		defer file.Close()
+		buf := bufio.NewWriter(file)
+		w := history.NewWriter(buf, format)
+		for _, s := range samples {
+			if err := w.Write(s); err != nil {
+				return errMsg(fmt.Errorf("export: %w", err))
+			}
+		}
+		err = w.Flush()
+		if err == nil {
+			err = buf.Flush()
+		}
+		if err != nil {
+			return errMsg(fmt.Errorf("export: %w", err))
+		}
+		return noticeMsg(lang.T("palette.export.done", len(samples), path))
+	}
+}
+
+// palette is the state of the command palette.
+type palette struct {
+	open     bool
+	input    textinput.Model
+	selected int
+	// command is the command whose argument is being entered, or nil.
+	command *paletteCommand
+	err     string
+}
+
+func newPalette(lang i18n.Printer) palette {
+	input := textinput.New()
+	input.Prompt = ": "
+	input.Placeholder = lang.T("palette.placeholder")
+	return palette{input: input}
+}
+
+// paletteItem is a line of the palette: a command, possibly with the
+// argument it was last run with.
+type paletteItem struct {
+	command *paletteCommand
+	entry   *paletteEntry // set for recent commands
+}
+
+func (i paletteItem) label() string {
+	if i.entry != nil {
+		return i.entry.String()
+	}
+	return i.command.name
+}
+
+// paletteItems lists recent commands followed by all commands if the
+// query is empty, and otherwise the commands matching it, best first.
+func (m Model) paletteItems() []paletteItem {
+	commands := m.paletteCommands()
+	find := func(name string) *paletteCommand {
+		for i := range commands {
+			if commands[i].name == name {
+				return &commands[i]
+			}
+		}
+		return nil
+	}
+
+	query := m.palette.input.Value()
+	var items []paletteItem
+	if query == "" {
+		for i := len(m.paletteHistory) - 1; i >= 0 && len(items) < paletteHistorySize; i-- {
+			entry := m.paletteHistory[i]
+			if c := find(entry.command); c != nil {
+				items = append(items, paletteItem{command: c, entry: &entry})
+			}
+		}
+		for i := range commands {
+			items = append(items, paletteItem{command: &commands[i]})
+		}
+		return items
+	}
+
+	scores := make(map[string]int)
+	for i := range commands {
+		if score, ok := fuzzyScore(query, commands[i].name); ok {
+			scores[commands[i].name] = score
+			items = append(items, paletteItem{command: &commands[i]})
+		}
+	}
+	sort.SliceStable(items, func(a, b int) bool {
+		na, nb := items[a].command.name, items[b].command.name
+		if scores[na] != scores[nb] {
+			return scores[na] > scores[nb]
+		}
+		return len(na) < len(nb)
+	})
+	return items
+}
+
+// fuzzyScore reports whether the characters of pattern appear in s in
+// order, ignoring case. Matches at the start of the name or of words
+// and runs of consecutive matches score higher.
+func fuzzyScore(pattern, s string) (int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	r := []rune(s)
+	score, run, j := 0, 0, 0
+	for i := 0; i < len(r) && j < len(p); i++ {
+		if unicode.ToLower(r[i]) != p[j] {
+			run = 0
+			continue
+		}
+		if p[j] == ' ' {
+			j++
+			continue
+		}
+		score++
+		switch {
+		case i == 0:
+			score += 5
+		case r[i-1] == ' ':
+			score += 3
+		}
+		run++
+		score += run
+		j++
+	}
+	return score, j == len(p)
+}
+
+func (m Model) openPalette() (Model, tea.Cmd) {
+	m.palette = newPalette(m.lang)
+	m.palette.open = true
+	return m, m.palette.input.Focus()
+}
+
+func (m Model) updatePalette(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := &m.palette
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		p.open = false
+		return m, nil
+	case "up", "ctrl+p":
+		p.selected = max(p.selected-1, 0)
+		return m, nil
+	case "down", "ctrl+n":
+		if p.command == nil {
+			p.selected = min(p.selected+1, max(len(m.paletteItems())-1, 0))
+		}
+		return m, nil
+	case "tab":
+		if p.command != nil && p.command.suggest != nil {
+			if s := p.command.suggest(m); len(s) > 0 {
+				p.input.SetValue(s[0])
+				p.input.CursorEnd()
+			}
+		}
+		return m, nil
+	case "enter":
+		if p.command != nil {
+			return m.runPaletteCommand(p.command, p.input.Value())
+		}
+		items := m.paletteItems()
+		if p.selected >= len(items) {
+			return m, nil
+		}
+		item := items[p.selected]
+		if item.entry != nil {
+			return m.runPaletteCommand(item.command, item.entry.arg)
+		}
+		if item.command.arg == "" {
+			return m.runPaletteCommand(item.command, "")
+		}
+		p.command = item.command
+		p.err = ""
+		p.input.SetValue("")
+		p.input.Prompt = item.command.name + " › "
+		p.input.Placeholder = item.command.arg
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	p.selected = 0
+	return m, cmd
+}
+
+// runPaletteCommand runs c and records it in the history. On an error the
+// palette stays open to correct the argument.
+func (m Model) runPaletteCommand(c *paletteCommand, arg string) (tea.Model, tea.Cmd) {
+	next, cmd, err := c.run(m, arg)
+	if err != nil {
+		m.palette.err = err.Error()
+		return m, nil
+	}
+	entry := paletteEntry{command: c.name, arg: arg}
+	recent := slices.DeleteFunc(slices.Clone(next.paletteHistory), func(e paletteEntry) bool { return e == entry })
+	next.paletteHistory = append(recent, entry)
+	next.palette.open = false
+	return next, cmd
+}
+
+func (m Model) renderPalette() string {
+	p := m.palette
+	width := min(64, m.width-6)
+	p.input.Width = width - lipgloss.Width(p.input.Prompt) - 1
+	lines := []string{p.input.View(), ""}
+
+	if p.command != nil {
+		if p.command.suggest != nil {
+			if s := p.command.suggest(m); len(s) > 0 {
+				lines = append(lines, m.styles.hint.Render(m.lang.T("palette.suggest", strings.Join(s, ", "))))
+			}
+		}
+	} else {
+		items := m.paletteItems()
+		if len(items) == 0 {
+			lines = append(lines, m.styles.hint.Render(m.lang.T("palette.no_match")))
+		}
+		// Scroll so that the selection stays visible.
+		rows := max(m.height-12, 3)
+		first := max(p.selected-rows+1, 0)
+		for i := first; i < len(items) && i < first+rows; i++ {
+			label := items[i].label()
+			if items[i].entry != nil {
+				label += m.styles.hint.Render("  " + m.lang.T("palette.recent"))
+			} else if items[i].command.arg != "" {
+				label += "…"
+			}
+			if i == p.selected {
+				lines = append(lines, m.styles.value.Render("▸ ")+label)
+			} else {
+				lines = append(lines, "  "+label)
+			}
+		}
+	}
+	if p.err != "" {
+		lines = append(lines, "", m.styles.error.Render(p.err))
+	}
+
+	return "\n" + lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(m.theme.Accent).
+		Padding(0, 1).
+		Width(width).
+		Render(strings.Join(lines, "\n"))
+}
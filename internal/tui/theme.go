@@ -0,0 +1,169 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme holds the colors of the TUI. Besides color, states are told apart by
+// symbols and text, so every theme also works for color-blind users.
+type Theme struct {
+	Name       string
+	Text       lipgloss.TerminalColor // labels
+	Accent     lipgloss.TerminalColor // values and the active tab
+	AccentText lipgloss.TerminalColor // text on Accent
+	Muted      lipgloss.TerminalColor // inactive tabs and hints
+	Good       lipgloss.TerminalColor // power output, no alarm, online
+	Warning    lipgloss.TerminalColor // retries, degraded connection
+	Bad        lipgloss.TerminalColor // alarms, errors, offline
+}
+
+var themes = []Theme{
+	{
+		Name:       "dark",
+		Text:       lipgloss.Color("#FAFAFA"),
+		Accent:     lipgloss.Color("#7D56F4"),
+		AccentText: lipgloss.Color("#FAFAFA"),
+		Muted:      lipgloss.Color("#666666"),
+		Good:       lipgloss.Color("#00FF00"),
+		Warning:    lipgloss.Color("#FF6600"),
+		Bad:        lipgloss.Color("#FF0000"),
+	},
+	{
+		Name:       "light",
+		Text:       lipgloss.Color("#1A1A1A"),
+		Accent:     lipgloss.Color("#5A3FD0"),
+		AccentText: lipgloss.Color("#FFFFFF"),
+		Muted:      lipgloss.Color("#6C6C6C"),
+		Good:       lipgloss.Color("#007A33"),
+		Warning:    lipgloss.Color("#B35900"),
+		Bad:        lipgloss.Color("#C00000"),
+	},
+	{
+		// Okabe-Ito colors, which stay distinct with all common forms of
+		// color blindness; blue and vermillion replace green and red.
+		Name:       "high-contrast",
+		Text:       lipgloss.Color("#FFFFFF"),
+		Accent:     lipgloss.Color("#F0E442"),
+		AccentText: lipgloss.Color("#000000"),
+		Muted:      lipgloss.Color("#B0B0B0"),
+		Good:       lipgloss.Color("#56B4E9"),
+		Warning:    lipgloss.Color("#E69F00"),
+		Bad:        lipgloss.Color("#D55E00"),
+	},
+	{
+		Name:       "mono",
+		Text:       lipgloss.NoColor{},
+		Accent:     lipgloss.NoColor{},
+		AccentText: lipgloss.NoColor{},
+		Muted:      lipgloss.NoColor{},
+		Good:       lipgloss.NoColor{},
+		Warning:    lipgloss.NoColor{},
+		Bad:        lipgloss.NoColor{},
+	},
+}
+
+// ThemeNames lists the built-in themes.
+func ThemeNames() []string {
+	names := []string{"auto"}
+	for _, t := range themes {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// ThemeByName returns a built-in theme. "auto" and "" pick mono if NO_COLOR
+// is set and otherwise dark or light depending on the terminal background.
+func ThemeByName(name string) (Theme, error) {
+	if name == "" || name == "auto" {
+		switch {
+		case os.Getenv("NO_COLOR") != "":
+			name = "mono"
+		case lipgloss.HasDarkBackground():
+			name = "dark"
+		default:
+			name = "light"
+		}
+	}
+	for _, t := range themes {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return Theme{}, fmt.Errorf("unknown theme %q (available: %s)", name, strings.Join(ThemeNames(), ", "))
+}
+
+var colorPattern = regexp.MustCompile(`^(#[0-9A-Fa-f]{6}|#[0-9A-Fa-f]{3}|[0-9]{1,3})$`)
+
+// WithColors overrides colors of the theme by role, e.g. "accent", with hex
+// colors or ANSI color numbers.
+func (t Theme) WithColors(colors map[string]string) (Theme, error) {
+	roles := map[string]*lipgloss.TerminalColor{
+		"text":        &t.Text,
+		"accent":      &t.Accent,
+		"accent-text": &t.AccentText,
+		"muted":       &t.Muted,
+		"good":        &t.Good,
+		"warning":     &t.Warning,
+		"bad":         &t.Bad,
+	}
+	for role, value := range colors {
+		c, ok := roles[role]
+		if !ok {
+			return t, fmt.Errorf("unknown color %q", role)
+		}
+		if !colorPattern.MatchString(value) {
+			return t, fmt.Errorf("color %s: invalid value %q (expected e.g. #7D56F4 or 205)", role, value)
+		}
+		*c = lipgloss.Color(value)
+	}
+	return t, nil
+}
+
+// styles are the lipgloss styles of all views, derived from a theme.
+type styles struct {
+	label       lipgloss.Style
+	value       lipgloss.Style
+	power       lipgloss.Style
+	hint        lipgloss.Style
+	header      lipgloss.Style
+	warning     lipgloss.Style
+	error       lipgloss.Style
+	ok          lipgloss.Style
+	alarm       lipgloss.Style
+	tab         lipgloss.Style
+	activeTab   lipgloss.Style
+	spinner     lipgloss.Style
+	button      lipgloss.Style
+	buttonHover lipgloss.Style
+}
+
+func newStyles(t Theme) styles {
+	s := styles{
+		label:   lipgloss.NewStyle().Foreground(t.Text),
+		value:   lipgloss.NewStyle().Bold(true).Foreground(t.Accent),
+		power:   lipgloss.NewStyle().Bold(true).Foreground(t.Good),
+		hint:    lipgloss.NewStyle().Foreground(t.Muted).Italic(true),
+		header:  lipgloss.NewStyle().Foreground(t.Muted).Bold(true),
+		warning: lipgloss.NewStyle().Foreground(t.Warning).Italic(true),
+		error:   lipgloss.NewStyle().Foreground(t.Bad).Bold(true),
+		ok:      lipgloss.NewStyle().Foreground(t.Good),
+		alarm:   lipgloss.NewStyle().Foreground(t.Bad).Bold(true),
+		tab:     lipgloss.NewStyle().Bold(true).Padding(0, 1).Margin(0, 1, 0, 0).Foreground(t.Muted),
+		spinner: lipgloss.NewStyle().Foreground(t.Accent),
+	}
+	s.activeTab = s.tab.Foreground(t.AccentText).Background(t.Accent)
+	s.button = lipgloss.NewStyle().Bold(true).Padding(0, 1).Foreground(t.Text).Background(t.Muted)
+	s.buttonHover = s.button.Foreground(t.AccentText).Background(t.Accent)
+	if _, mono := t.Accent.(lipgloss.NoColor); mono {
+		s.activeTab = s.activeTab.Reverse(true)
+		s.alarm = s.alarm.Reverse(true)
+		s.button = s.button.Reverse(true)
+		s.buttonHover = s.button.Underline(true)
+	}
+	return s
+}
@@ -3,6 +3,7 @@ package tui
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/help"
@@ -10,6 +11,10 @@ import (
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/emissions"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/history"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/i18n"
+	"github.com/niclaszll/apsystems-ez1-tui/internal/units"
 	"github.com/niclaszll/apsystems-ez1-tui/pkg/apsystems"
 )
 
@@ -20,72 +25,33 @@ const (
 	ViewDeviceInfo
 	ViewAlarms
 	ViewPowerControl
+	ViewDiagnostics
+
+	numViews = iota
 )
 
-type keyMap struct {
-	Help        key.Binding
-	Quit        key.Binding
-	Refresh     key.Binding
-	Tab         key.Binding
-	PowerOn     key.Binding
-	PowerOff    key.Binding
-	IncreasePwr key.Binding
-	DecreasePwr key.Binding
-}
-
-func (k keyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Help, k.Quit, k.Tab, k.Refresh}
-}
-
-func (k keyMap) FullHelp() [][]key.Binding {
-	return [][]key.Binding{
-		{k.Tab, k.Refresh, k.Help, k.Quit},
-		{k.PowerOn, k.PowerOff},
-		{k.IncreasePwr, k.DecreasePwr},
-	}
-}
-
-var keys = keyMap{
-	Help: key.NewBinding(
-		key.WithKeys("?"),
-		key.WithHelp("?", "toggle help"),
-	),
-	Quit: key.NewBinding(
-		key.WithKeys("q", "ctrl+c"),
-		key.WithHelp("q", "quit"),
-	),
-	Refresh: key.NewBinding(
-		key.WithKeys("r"),
-		key.WithHelp("r", "refresh"),
-	),
-	Tab: key.NewBinding(
-		key.WithKeys("tab"),
-		key.WithHelp("tab", "next view"),
-	),
-	PowerOn: key.NewBinding(
-		key.WithKeys("o"),
-		key.WithHelp("o", "power on"),
-	),
-	PowerOff: key.NewBinding(
-		key.WithKeys("f"),
-		key.WithHelp("f", "power off"),
-	),
-	IncreasePwr: key.NewBinding(
-		key.WithKeys("+", "="),
-		key.WithHelp("+", "increase power"),
-	),
-	DecreasePwr: key.NewBinding(
-		key.WithKeys("-", "_"),
-		key.WithHelp("-", "decrease power"),
-	),
+// viewIDs are the message IDs of the view names.
+var viewIDs = [numViews]string{"view.dashboard", "view.device_info", "view.alarms", "view.power_control", "view.diagnostics"}
+
+// Device is the source of readings and target of control commands. It is
+// satisfied by *apsystems.Client and by the gateway poller.
+type Device interface {
+	apsystems.Source
+	SetMaxPower(ctx context.Context, watts int) error
+	SetDevicePowerStatus(ctx context.Context, status string) error
 }
 
 type Model struct {
-	client      *apsystems.Client
+	client      Device
+	watcher     *apsystems.Watcher
+	watchOpts   []apsystems.WatcherOption
+	updates     <-chan apsystems.Update
+	ctx         context.Context
+	stop        context.CancelFunc
 	currentView View
 	spinner     spinner.Model
 	help        help.Model
-	keys        keyMap
+	keys        KeyMap
 	loading     bool
 	err         error
 	stats       *apsystems.Statistics
@@ -93,192 +59,283 @@ type Model struct {
 	alarmInfo   *apsystems.AlarmInfo
 	powerStatus *apsystems.PowerStatus
 	powerLimit  *apsystems.PowerLimit
-	width       int
-	height      int
-	showHelp    bool
+	emissions   *emissions.Estimator
+	avoided     *emissions.Avoided
+	history     *history.Store
+	theme       Theme
+	styles      styles
+	units       units.Formatter
+	lang        i18n.Printer
+	zones       *zones
+	layout      Layout
+	kiosk       *Kiosk
+	// now is the time of the last kiosk tick.
+	now time.Time
+	// powerHistory holds recent power readings for the chart.
+	powerHistory []powerSample
+	hover        string
+	palette      palette
+	// paletteHistory holds the commands run from the palette, most recent
+	// last.
+	paletteHistory []paletteEntry
+	// connect opens another device for the switch device command.
+	connect func(addr string) (Device, error)
+	devices map[string]string
+	// notice is the result of the last palette command, shown until the
+	// next key press.
+	notice string
+	// warning is shown in the header for the whole session.
+	warning string
+	// pendingLimit is a limit chosen with the scroll wheel that has not
+	// been confirmed yet, or 0.
+	pendingLimit int
+	width        int
+	height       int
+	showHelp     bool
+}
+
+// Option configures optional Model features.
+type Option func(*Model)
+
+// WithEmissions enables the avoided CO₂ estimate on the dashboard.
+func WithEmissions(intensity emissions.Intensity) Option {
+	return func(m *Model) {
+		m.emissions = emissions.NewEstimator(intensity)
+	}
+}
+
+// WithHistory records every statistics sample to the history store.
+func WithHistory(store *history.Store) Option {
+	return func(m *Model) {
+		m.history = store
+	}
+}
+
+// WithKeys replaces the default key bindings.
+func WithKeys(keys KeyMap) Option {
+	return func(m *Model) {
+		m.keys = keys
+	}
+}
+
+// WithLanguage sets the language of the TUI. The default follows the
+// locale of the environment.
+func WithLanguage(p i18n.Printer) Option {
+	return func(m *Model) {
+		m.lang = p
+	}
+}
+
+// WithTheme sets the colors of the TUI. The default is the dark theme.
+func WithTheme(theme Theme) Option {
+	return func(m *Model) {
+		m.theme = theme
+	}
+}
+
+// WithUnits sets how values and times are formatted. The default follows
+// the locale of the environment.
+func WithUnits(f units.Formatter) Option {
+	return func(m *Model) {
+		m.units = f
+	}
+}
+
+// WithWarning shows a warning in the header for the whole session, e.g.
+// that the web dashboard can be reached without a token.
+func WithWarning(text string) Option {
+	return func(m *Model) {
+		m.warning = text
+	}
+}
+
+// WithWatcherOptions configures how the device is polled, e.g. with
+// apsystems.WithDaylight to pause overnight.
+func WithWatcherOptions(opts ...apsystems.WatcherOption) Option {
+	return func(m *Model) {
+		m.watchOpts = append(m.watchOpts, opts...)
+	}
+}
+
+// updateMsg is an update received from the watcher that published to
+// channel from; updates of a replaced watcher are dropped.
+type updateMsg struct {
+	apsystems.Update
+	from <-chan apsystems.Update
 }
 
-type tickMsg time.Time
-type statsMsg *apsystems.Statistics
-type deviceInfoMsg *apsystems.DeviceInfo
-type alarmInfoMsg *apsystems.AlarmInfo
-type powerStatusMsg *apsystems.PowerStatus
-type powerLimitMsg *apsystems.PowerLimit
 type errMsg error
 
-func NewModel(client *apsystems.Client) Model {
-	s := spinner.New()
-	s.Spinner = spinner.Dot
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+// defaultLanguage follows the environment; it falls back to English rather
+// than failing.
+func defaultLanguage() i18n.Printer {
+	p, _ := i18n.New("")
+	return p
+}
 
-	return Model{
+func NewModel(client Device, opts ...Option) Model {
+	m := Model{
 		client:      client,
 		currentView: ViewDashboard,
-		spinner:     s,
 		help:        help.New(),
-		keys:        keys,
+		keys:        DefaultKeys(),
 		loading:     true,
 		showHelp:    false,
+		theme:       themes[0],
+		units:       units.New(""),
+		lang:        defaultLanguage(),
+		zones:       newZones(),
 	}
+	for _, opt := range opts {
+		opt(&m)
+	}
+	m.spinner = spinner.New(spinner.WithSpinner(spinner.Dot))
+	m.palette = newPalette(m.lang)
+	m.applyTheme(m.theme)
+	m.startWatcher()
+	return m
 }
 
-func (m Model) Init() tea.Cmd {
-	return tea.Batch(
-		m.spinner.Tick,
-		tickCmd(),
-		fetchStats(m.client),
-		fetchDeviceInfo(m.client),
-		fetchPowerStatus(m.client),
-		fetchPowerLimit(m.client),
-		fetchAlarmInfo(m.client),
-	)
-}
-
-func tickCmd() tea.Cmd {
-	return tea.Tick(time.Second*10, func(t time.Time) tea.Msg {
-		return tickMsg(t)
-	})
+// applyTheme derives all styles from t.
+func (m *Model) applyTheme(t Theme) {
+	m.theme = t
+	m.styles = newStyles(t)
+	m.spinner.Style = m.styles.spinner
+	m.help.Styles.ShortKey = m.help.Styles.ShortKey.Foreground(t.Text)
+	m.help.Styles.FullKey = m.help.Styles.FullKey.Foreground(t.Text)
+	m.help.Styles.ShortDesc = m.help.Styles.ShortDesc.Foreground(t.Muted)
+	m.help.Styles.FullDesc = m.help.Styles.FullDesc.Foreground(t.Muted)
+	m.help.Styles.ShortSeparator = m.help.Styles.ShortSeparator.Foreground(t.Muted)
+	m.help.Styles.FullSeparator = m.help.Styles.FullSeparator.Foreground(t.Muted)
 }
 
-func fetchStats(client *apsystems.Client) tea.Cmd {
-	return func() tea.Msg {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		stats, err := client.GetStatistics(ctx)
-		if err != nil {
-			return errMsg(err)
-		}
-		return statsMsg(stats)
-	}
+// startWatcher creates the watcher polling m.client. It is started by
+// runWatcher.
+func (m *Model) startWatcher() {
+	// Every reading of the output data is published so that the history
+	// keeps one sample per poll even when nothing changes.
+	watchOpts := append([]apsystems.WatcherOption{apsystems.WithDuplicates(apsystems.EndpointOutput)}, m.watchOpts...)
+	m.watcher = apsystems.NewWatcher(m.client, watchOpts...)
+	m.updates, _ = m.watcher.Subscribe(16)
+	m.ctx, m.stop = context.WithCancel(context.Background())
 }
 
-func fetchDeviceInfo(client *apsystems.Client) tea.Cmd {
-	return func() tea.Msg {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		info, err := client.GetDeviceInfo(ctx)
-		if err != nil {
-			return errMsg(err)
-		}
-		return deviceInfoMsg(info)
+func (m Model) Init() tea.Cmd {
+	cmds := []tea.Cmd{
+		m.spinner.Tick,
+		m.runWatcher(),
+		waitForUpdate(m.updates),
 	}
-}
-
-func fetchAlarmInfo(client *apsystems.Client) tea.Cmd {
-	return func() tea.Msg {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		info, err := client.GetAlarmInfo(ctx)
-		if err != nil {
-			return errMsg(err)
-		}
-		return alarmInfoMsg(info)
+	if m.kiosk != nil {
+		cmds = append(cmds, kioskTick())
 	}
+	return tea.Batch(cmds...)
 }
 
-func fetchPowerStatus(client *apsystems.Client) tea.Cmd {
+func (m Model) runWatcher() tea.Cmd {
 	return func() tea.Msg {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		status, err := client.GetDevicePowerStatus(ctx)
-		if err != nil {
-			return errMsg(err)
-		}
-		return powerStatusMsg(status)
+		m.watcher.Run(m.ctx)
+		return nil
 	}
 }
 
-func fetchPowerLimit(client *apsystems.Client) tea.Cmd {
+func waitForUpdate(updates <-chan apsystems.Update) tea.Cmd {
 	return func() tea.Msg {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		limit, err := client.GetMaxPower(ctx)
-		if err != nil {
-			return errMsg(err)
+		u, ok := <-updates
+		if !ok {
+			return nil
 		}
-		return powerLimitMsg(limit)
+		return updateMsg{u, updates}
 	}
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		m.notice = ""
+		if m.palette.open {
+			return m.updatePalette(msg)
+		}
 		switch {
 		case key.Matches(msg, m.keys.Quit):
+			m.stop()
 			return m, tea.Quit
+		case m.kiosk != nil:
+			return m, nil
+		case key.Matches(msg, m.keys.Palette):
+			return m.openPalette()
 		case key.Matches(msg, m.keys.Help):
 			m.showHelp = !m.showHelp
 			return m, nil
-		case key.Matches(msg, m.keys.Tab):
-			m.currentView = (m.currentView + 1) % 4
+		case key.Matches(msg, m.keys.NextView):
+			m.currentView = (m.currentView + 1) % numViews
+			return m, nil
+		case key.Matches(msg, m.keys.PrevView):
+			m.currentView = (m.currentView + numViews - 1) % numViews
+			return m, nil
+		case key.Matches(msg, m.keys.JumpView):
+			m.currentView = View(msg.String()[0] - '1')
 			return m, nil
 		case key.Matches(msg, m.keys.Refresh):
-			return m, tea.Batch(
-				fetchStats(m.client),
-				fetchDeviceInfo(m.client),
-				fetchPowerStatus(m.client),
-				fetchPowerLimit(m.client),
-				fetchAlarmInfo(m.client),
-			)
+			m.watcher.Refresh()
+			return m, nil
+		case key.Matches(msg, m.keys.Confirm):
+			if m.powerControlVisible() && m.pendingLimit != 0 {
+				watts := m.pendingLimit
+				m.pendingLimit = 0
+				return m, m.setMaxPower(watts)
+			}
+		case key.Matches(msg, m.keys.Cancel):
+			m.pendingLimit = 0
+			return m, nil
 		case key.Matches(msg, m.keys.PowerOn):
-			if m.currentView == ViewPowerControl {
+			if m.powerKeysActive() {
 				return m, m.setPowerStatus("ON")
 			}
 		case key.Matches(msg, m.keys.PowerOff):
-			if m.currentView == ViewPowerControl {
+			if m.powerKeysActive() {
 				return m, m.setPowerStatus("OFF")
 			}
 		case key.Matches(msg, m.keys.IncreasePwr):
-			if m.currentView == ViewPowerControl && m.powerLimit != nil {
-				newPower := int(m.powerLimit.Data.MaxPower) + 50
-				if newPower <= 800 {
-					return m, m.setMaxPower(newPower)
-				}
+			if m.powerKeysActive() {
+				return m, m.stepLimit(limitStep)
 			}
 		case key.Matches(msg, m.keys.DecreasePwr):
-			if m.currentView == ViewPowerControl && m.powerLimit != nil {
-				newPower := int(m.powerLimit.Data.MaxPower) - 50
-				if newPower >= 30 {
-					return m, m.setMaxPower(newPower)
-				}
+			if m.powerKeysActive() {
+				return m, m.stepLimit(-limitStep)
 			}
 		}
 
+	case tea.MouseMsg:
+		if m.kiosk != nil || m.palette.open {
+			return m, nil
+		}
+		return m.handleMouse(msg)
+
+	case kioskTickMsg:
+		m.now = time.Time(msg)
+		return m, kioskTick()
+
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
 		m.help.Width = msg.Width
 		return m, nil
 
-	case tickMsg:
-		return m, fetchStats(m.client)
-
-	case statsMsg:
-		m.stats = msg
-		m.loading = false
-		m.err = nil
-		return m, tickCmd()
-
-	case deviceInfoMsg:
-		m.deviceInfo = msg
-		return m, nil
-
-	case alarmInfoMsg:
-		m.alarmInfo = msg
-		return m, nil
-
-	case powerStatusMsg:
-		m.powerStatus = msg
-		return m, nil
+	case updateMsg:
+		if msg.from != m.updates {
+			return m, nil
+		}
+		return m.applyUpdate(msg.Update)
 
-	case powerLimitMsg:
-		m.powerLimit = msg
+	case noticeMsg:
+		m.notice = string(msg)
 		return m, nil
 
 	case errMsg:
 		m.err = msg
 		m.loading = false
-		return m, tickCmd()
+		return m, nil
 
 	case spinner.TickMsg:
 		var cmd tea.Cmd
@@ -289,6 +346,50 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+func (m Model) applyUpdate(u apsystems.Update) (tea.Model, tea.Cmd) {
+	next := waitForUpdate(m.updates)
+	if u.Err != nil {
+		m.err = u.Err
+		m.loading = false
+		return m, next
+	}
+
+	switch u.Endpoint {
+	case apsystems.EndpointOutput:
+		m.stats = u.Statistics
+		m.recordPower(u.Time, u.Statistics.TotalPower)
+		m.loading = false
+		m.err = nil
+		if m.emissions != nil {
+			avoided := m.emissions.Update(m.stats)
+			m.avoided = &avoided
+		}
+		if m.history != nil {
+			return m, tea.Batch(next, m.recordSample())
+		}
+	case apsystems.EndpointDeviceInfo:
+		m.deviceInfo = u.DeviceInfo
+	case apsystems.EndpointAlarm:
+		m.alarmInfo = u.Alarm
+	case apsystems.EndpointPowerStatus:
+		m.powerStatus = u.Status
+	case apsystems.EndpointMaxPower:
+		m.powerLimit = u.Limit
+	}
+	return m, next
+}
+
+func (m Model) recordSample() tea.Cmd {
+	sample := history.NewSample(m.stats, m.powerLimit, m.powerStatus, m.alarmInfo)
+	store := m.history
+	return func() tea.Msg {
+		if err := store.Append(sample); err != nil {
+			return errMsg(fmt.Errorf("record history: %w", err))
+		}
+		return nil
+	}
+}
+
 func (m Model) setPowerStatus(status string) tea.Cmd {
 	return func() tea.Msg {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -297,7 +398,8 @@ func (m Model) setPowerStatus(status string) tea.Cmd {
 		if err != nil {
 			return errMsg(err)
 		}
-		return fetchPowerStatus(m.client)()
+		m.watcher.Refresh(apsystems.EndpointPowerStatus)
+		return nil
 	}
 }
 
@@ -309,19 +411,37 @@ func (m Model) setMaxPower(watts int) tea.Cmd {
 		if err != nil {
 			return errMsg(err)
 		}
-		return fetchPowerLimit(m.client)()
+		m.watcher.Refresh(apsystems.EndpointMaxPower)
+		return nil
 	}
 }
 
 func (m Model) View() string {
 	if m.width == 0 {
-		return "Initializing..."
+		return m.lang.T("app.initializing")
+	}
+	if m.width < minWidth || m.height < minHeight {
+		// Nothing clickable is shown, so forget the zones of the last
+		// frame.
+		m.zones.clear()
+		return m.renderTooSmall()
 	}
+	if m.kiosk != nil {
+		return m.renderKiosk()
+	}
+
+	header := m.renderHeader()
+	footer := m.renderFooter()
 
 	var content string
 
 	switch m.currentView {
 	case ViewDashboard:
+		if m.gridLayout() {
+			height := m.height - lipgloss.Height(header) - lipgloss.Height(footer)
+			content = m.renderGrid(m.width-2, height)
+			break
+		}
 		content = m.renderDashboard()
 	case ViewDeviceInfo:
 		content = m.renderDeviceInfo()
@@ -329,207 +449,309 @@ func (m Model) View() string {
 		content = m.renderAlarms()
 	case ViewPowerControl:
 		content = m.renderPowerControl()
+	case ViewDiagnostics:
+		content = m.renderDiagnostics()
+	}
+	if m.palette.open {
+		content = m.renderPalette()
 	}
-
-	header := m.renderHeader()
-	footer := m.renderFooter()
 
 	// Apply some padding to align with header
 	contentStyle := lipgloss.NewStyle().Padding(0, 1)
 	content = contentStyle.Render(content)
 	footer = contentStyle.Render(footer)
 
-	return lipgloss.JoinVertical(lipgloss.Left, header, content, footer)
+	view := m.zones.scan(lipgloss.JoinVertical(lipgloss.Left, header, content, footer))
+	return fit(view, m.width, m.height)
 }
 
 func (m Model) renderHeader() string {
-	tabs := []string{"Dashboard", "Device Info", "Alarms", "Power Control"}
 	var renderedTabs []string
 
-	for i, tab := range tabs {
-		style := lipgloss.NewStyle().Bold(true).Padding(0, 1).Margin(0, 1, 0, 0)
-		if View(i) == m.currentView {
-			style = style.
-				Foreground(lipgloss.Color("#FAFAFA")).
-				Background(lipgloss.Color("#7D56F4"))
-		} else {
-			style = style.Foreground(lipgloss.Color("#666666"))
+	for i := range View(numViews) {
+		style := m.styles.tab
+		if i == m.currentView {
+			style = m.styles.activeTab
 		}
-		renderedTabs = append(renderedTabs, style.Render(tab))
+		renderedTabs = append(renderedTabs, m.zones.mark(tabZone(i), style.Render(m.viewName(i))))
+	}
+	status := []string{m.renderPollState()}
+	if health, ok := m.health(); ok {
+		status = append(status, m.renderHealthBadge(health))
+	}
+	if m.warning != "" {
+		status = append(status, m.styles.warning.Padding(0, 1).Render("⚠ "+m.warning))
+	}
+
+	header := lipgloss.JoinHorizontal(lipgloss.Top, append(renderedTabs, status...)...)
+	if lipgloss.Width(header) <= m.width {
+		return header
+	}
+	// On narrow terminals only the current view is named.
+	current := fmt.Sprintf("%s %d/%d", m.viewName(m.currentView), m.currentView+1, numViews)
+	return lipgloss.JoinHorizontal(lipgloss.Top, append([]string{m.styles.activeTab.Render(current)}, status...)...)
+}
+
+func (m Model) viewName(v View) string {
+	return m.lang.T(viewIDs[v])
+}
+
+func (m Model) renderPollState() string {
+	style := lipgloss.NewStyle().Padding(0, 1).Italic(true)
+	switch m.watcher.State().Mode {
+	case apsystems.PollBackoff:
+		return style.Foreground(m.theme.Warning).Render("⟳ " + m.pollState())
+	case apsystems.PollSleeping:
+		return style.Foreground(m.theme.Muted).Render("☾ " + m.pollState())
+	default:
+		return style.Foreground(m.theme.Muted).Render("● " + m.pollState())
+	}
+}
+
+// pollState describes the polling mode like apsystems.PollState.String,
+// translated and with times in the configured clock format.
+func (m Model) pollState() string {
+	state := m.watcher.State()
+	switch state.Mode {
+	case apsystems.PollBackoff:
+		return m.lang.T("poll.backoff", m.units.Time(state.Until))
+	case apsystems.PollSleeping:
+		return m.lang.T("poll.sleeping", m.units.Clock(state.Until))
+	default:
+		return m.lang.T("poll.polling")
+	}
+}
+
+// sleeping reports whether polling is paused overnight; errors from the
+// offline inverter are expected then and not shown.
+func (m Model) sleeping() bool {
+	return m.watcher.State().Mode == apsystems.PollSleeping
+}
+
+// labelStyle returns the label style padded to the longest of labels, so
+// that values line up however long the translations are.
+func (m Model) labelStyle(labels ...string) lipgloss.Style {
+	width := 0
+	for _, l := range labels {
+		width = max(width, lipgloss.Width(l))
 	}
+	return m.styles.label.Width(width + 2)
+}
 
-	return lipgloss.JoinHorizontal(lipgloss.Top, renderedTabs...)
+// labelValue is a row of a column of labelled values.
+type labelValue struct {
+	label, value string
 }
 
 func (m Model) renderFooter() string {
+	if m.palette.open {
+		return "\n" + m.styles.hint.Render(m.lang.T("palette.footer"))
+	}
+	if m.notice != "" {
+		return "\n" + m.styles.ok.Render(m.notice)
+	}
+	keys := m.helpKeys()
 	if m.showHelp {
-		return "\n" + m.help.FullHelpView(m.keys.FullHelp())
+		return "\n" + m.help.FullHelpView(keys.FullHelp())
 	}
-	return "\n" + m.help.ShortHelpView(m.keys.ShortHelp())
+	return "\n" + m.help.ShortHelpView(keys.ShortHelp())
 }
 
 func (m Model) renderDashboard() string {
+	if m.stats == nil && m.sleeping() {
+		return "\n" + m.lang.T("dashboard.offline_overnight", m.pollState(), m.keys.Refresh.Help().Key)
+	}
+
 	if m.loading && m.stats == nil {
-		return fmt.Sprintf("\n%s Loading...", m.spinner.View())
+		return fmt.Sprintf("\n%s %s", m.spinner.View(), m.lang.T("dashboard.loading"))
 	}
 
 	if m.stats == nil {
 		if m.err != nil {
-			errorStyle := lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#FF0000")).
-				Bold(true)
-			return errorStyle.Render(fmt.Sprintf("\nError: %v\n\nRetrying...", m.err))
+			return m.styles.error.Render("\n" + m.lang.T("dashboard.error", m.err))
 		}
-		return "\nNo data available"
+		return "\n" + m.lang.T("dashboard.no_data")
 	}
 
-	labelStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#FAFAFA")).
-		Width(25)
-
-	valueStyle := lipgloss.NewStyle().
-		Bold(true).
-		Foreground(lipgloss.Color("#7D56F4"))
-
-	powerStyle := lipgloss.NewStyle().
-		Bold(true).
-		Foreground(lipgloss.Color("#00FF00"))
+	t := m.lang.T
+	labelStyle := m.labelStyle(t("dashboard.power"), t("dashboard.energy_today"), t("dashboard.energy_lifetime"),
+		t("dashboard.co2_today"), t("dashboard.co2_lifetime"), t("dashboard.last_update"), t("dashboard.power_status"), t("dashboard.power_limit"))
+	valueStyle := m.styles.value
+	powerStyle := m.styles.power
 
 	lines := []string{
 		"",
-		labelStyle.Render("Current Power Output:") + powerStyle.Render(fmt.Sprintf("%d W", m.stats.TotalPower)),
-		labelStyle.Render("Energy Today:") + valueStyle.Render(fmt.Sprintf("%.3f kWh", m.stats.TotalEnergyToday)),
-		labelStyle.Render("Lifetime Energy:") + valueStyle.Render(fmt.Sprintf("%.3f kWh", m.stats.TotalEnergyLifetime)),
-		"",
-		labelStyle.Render("Last Update:") + valueStyle.Render(m.stats.LastUpdate.Format("15:04:05")),
+		labelStyle.Render(t("dashboard.power")) + powerStyle.Render(m.units.Power(float64(m.stats.TotalPower))),
+		labelStyle.Render(t("dashboard.energy_today")) + valueStyle.Render(m.units.Energy(m.stats.TotalEnergyToday)),
+		labelStyle.Render(t("dashboard.energy_lifetime")) + valueStyle.Render(m.units.Energy(m.stats.TotalEnergyLifetime)),
 	}
 
+	if invalid := m.stats.Invalid(); len(invalid) > 0 {
+		lines = append(lines, m.styles.hint.Render(t("dashboard.invalid", strings.Join(invalid, ", "))))
+	}
+
+	if m.avoided != nil {
+		lines = append(lines,
+			labelStyle.Render(t("dashboard.co2_today"))+valueStyle.Render(m.units.Mass(m.avoided.Today)),
+			labelStyle.Render(t("dashboard.co2_lifetime"))+valueStyle.Render(m.units.Mass(m.avoided.Lifetime)),
+		)
+	}
+
+	lines = append(lines,
+		"",
+		labelStyle.Render(t("dashboard.last_update"))+valueStyle.Render(m.units.Time(m.stats.LastUpdate)),
+	)
+
 	if m.powerStatus != nil {
-		var statusText string
-		switch int(m.powerStatus.Data.Status) {
-		case 0:
-			statusText = "ON"
-		case 1:
-			statusText = "OFF"
-		default:
-			statusText = "UNKNOWN"
-		}
-		lines = append(lines, labelStyle.Render("Power Status:")+valueStyle.Render(statusText))
+		lines = append(lines, labelStyle.Render(t("dashboard.power_status"))+valueStyle.Render(m.powerState(m.powerStatus.Data.Status)))
 	}
 
 	if m.powerLimit != nil {
-		lines = append(lines, labelStyle.Render("Max Power Limit:")+valueStyle.Render(fmt.Sprintf("%d W", int(m.powerLimit.Data.MaxPower))))
+		lines = append(lines, labelStyle.Render(t("dashboard.power_limit"))+valueStyle.Render(m.units.Power(float64(m.powerLimit.Data.MaxPower))))
 	}
 
-	if m.err != nil {
-		errorStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FF6600")).
-			Italic(true)
+	if m.err != nil && !m.sleeping() {
 		lines = append(lines, "")
-		lines = append(lines, errorStyle.Render(fmt.Sprintf("⚠ Last refresh failed: %v", m.err)))
+		lines = append(lines, m.styles.warning.Render(t("dashboard.refresh_failed", m.err)))
 	}
 
 	return lipgloss.JoinVertical(lipgloss.Left, lines...)
 }
 
+// powerState translates the on/off state of the inverter.
+func (m Model) powerState(s apsystems.PowerState) string {
+	switch s {
+	case apsystems.PowerOn:
+		return m.lang.T("power_state.on")
+	case apsystems.PowerOff:
+		return m.lang.T("power_state.off")
+	case apsystems.PowerUnknown:
+		return m.lang.T("power_state.unknown")
+	}
+	return s.String()
+}
+
 func (m Model) renderDeviceInfo() string {
 	if m.deviceInfo == nil {
-		return "\nLoading device information..."
+		return "\n" + m.lang.T("device.loading")
 	}
 
-	labelStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#FAFAFA")).
-		Width(20)
-
-	valueStyle := lipgloss.NewStyle().
-		Bold(true).
-		Foreground(lipgloss.Color("#7D56F4"))
+	t := m.lang.T
+	labelStyle := m.labelStyle(t("device.id"), t("device.firmware"), t("device.ip"), t("device.ssid"), t("device.min_power"), t("device.max_power"))
+	valueStyle := m.styles.value
 
 	lines := []string{
 		"",
-		labelStyle.Render("Device ID:") + valueStyle.Render(m.deviceInfo.Data.DeviceID),
-		labelStyle.Render("Firmware:") + valueStyle.Render(m.deviceInfo.Data.Firmware),
+		labelStyle.Render(t("device.id")) + valueStyle.Render(m.deviceInfo.Data.DeviceID),
+		labelStyle.Render(t("device.firmware")) + valueStyle.Render(m.deviceInfo.Data.Firmware),
 		"",
-		labelStyle.Render("IP Address:") + valueStyle.Render(m.deviceInfo.Data.IPAddr),
-		labelStyle.Render("SSID:") + valueStyle.Render(m.deviceInfo.Data.SSIDName),
+		labelStyle.Render(t("device.ip")) + valueStyle.Render(m.deviceInfo.Data.IPAddr),
+		labelStyle.Render(t("device.ssid")) + valueStyle.Render(m.deviceInfo.Data.SSIDName),
 		"",
-		labelStyle.Render("Min Power:") + valueStyle.Render(fmt.Sprintf("%d W", int(m.deviceInfo.Data.MinPower))),
-		labelStyle.Render("Max Power:") + valueStyle.Render(fmt.Sprintf("%d W", int(m.deviceInfo.Data.MaxPower))),
+		labelStyle.Render(t("device.min_power")) + valueStyle.Render(m.units.Power(float64(m.deviceInfo.Data.MinPower))),
+		labelStyle.Render(t("device.max_power")) + valueStyle.Render(m.units.Power(float64(m.deviceInfo.Data.MaxPower))),
 	}
 
 	return lipgloss.JoinVertical(lipgloss.Left, lines...)
 }
 
-func (m Model) renderAlarms() string {
+// renderAlarms shows the alarm flags, followed by extra fields aligned with
+// them.
+func (m Model) renderAlarms(extra ...labelValue) string {
 	if m.alarmInfo == nil {
-		return "\nLoading alarm information..."
+		return "\n" + m.lang.T("alarms.loading")
 	}
 
-	labelStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#FAFAFA")).
-		Width(25)
+	// Symbols keep the states apart without relying on color.
+	renderStatus := func(value apsystems.AlarmState, field string) string {
+		switch {
+		case !m.alarmInfo.Valid(field):
+			return m.styles.hint.Render("? " + m.lang.T("alarms.na"))
+		case value == apsystems.AlarmActive:
+			return m.styles.alarm.Render("✗ " + m.lang.T("alarm_state.alarm"))
+		case value.Active():
+			return m.styles.alarm.Render("✗ " + value.String())
+		}
+		return m.styles.ok.Render("✓ " + m.lang.T("alarm_state.ok"))
+	}
 
-	okStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#00FF00"))
+	a := m.alarmInfo.Data
+	rows := []struct {
+		label, field string
+		value        apsystems.AlarmState
+	}{
+		{m.lang.T("alarms.og"), "og", a.Og},
+		{m.lang.T("alarms.isce1"), "isce1", a.Isce1},
+		{m.lang.T("alarms.isce2"), "isce2", a.Isce2},
+		{m.lang.T("alarms.oe"), "oe", a.Oe},
+	}
+	var labels []string
+	for _, row := range rows {
+		labels = append(labels, row.label)
+	}
+	for _, f := range extra {
+		labels = append(labels, f.label)
+	}
+	labelStyle := m.labelStyle(labels...)
 
-	alarmStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#FF0000")).
-		Bold(true)
+	lines := []string{""}
+	for _, row := range rows {
+		lines = append(lines, m.zones.mark("alarm:"+row.field, labelStyle.Render(row.label)+renderStatus(row.value, row.field)))
+	}
 
-	renderStatus := func(value int) string {
-		if value == 0 {
-			return okStyle.Render("OK")
-		}
-		return alarmStyle.Render("ALARM")
+	if field, ok := strings.CutPrefix(m.hover, "alarm:"); ok {
+		lines = append(lines, "", m.renderTooltip(m.lang.T("alarms."+field+".description")))
 	}
 
-	lines := []string{
-		"",
-		labelStyle.Render("Grid Fault:") + renderStatus(int(m.alarmInfo.Data.Og)),
-		labelStyle.Render("PV1 Short Circuit:") + renderStatus(int(m.alarmInfo.Data.Isce1)),
-		labelStyle.Render("PV2 Short Circuit:") + renderStatus(int(m.alarmInfo.Data.Isce2)),
-		labelStyle.Render("Output Error:") + renderStatus(int(m.alarmInfo.Data.Oe)),
+	if len(extra) > 0 {
+		lines = append(lines, "")
+	}
+	for _, f := range extra {
+		lines = append(lines, labelStyle.Render(f.label)+m.styles.value.Render(f.value))
 	}
 
 	return lipgloss.JoinVertical(lipgloss.Left, lines...)
 }
 
 func (m Model) renderPowerControl() string {
-	labelStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#FAFAFA")).
-		Width(20)
-
-	valueStyle := lipgloss.NewStyle().
-		Bold(true).
-		Foreground(lipgloss.Color("#7D56F4"))
+	t := m.lang.T
+	labelStyle := m.labelStyle(t("control.status"), t("dashboard.power_limit"), t("control.new_limit"))
+	valueStyle := m.styles.value
 
-	helpStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#666666")).
-		Italic(true)
+	helpStyle := m.styles.hint
 
 	lines := []string{""}
 
 	if m.powerStatus != nil {
-		var statusText string
-		switch int(m.powerStatus.Data.Status) {
-		case 0:
-			statusText = "ON (Normal)"
-		case 1:
-			statusText = "OFF"
-		default:
-			statusText = "UNKNOWN"
+		statusText := m.powerState(m.powerStatus.Data.Status)
+		if m.powerStatus.Data.Status == apsystems.PowerOn {
+			statusText = t("control.normal", statusText)
 		}
-		lines = append(lines, labelStyle.Render("Current Status:")+valueStyle.Render(statusText))
+		lines = append(lines, labelStyle.Render(t("control.status"))+valueStyle.Render(statusText))
 		lines = append(lines, "")
-		lines = append(lines, helpStyle.Render("Press 'o' for ON, 'f' for OFF"))
+		lines = append(lines, m.button("power-on", t("power_state.on"))+" "+m.button("power-off", t("power_state.off")))
+		if m.powerKeysActive() {
+			lines = append(lines, helpStyle.Render(t("control.power_keys", m.keys.PowerOn.Help().Key, m.keys.PowerOff.Help().Key)))
+		}
 		lines = append(lines, "")
 	}
 
 	if m.powerLimit != nil {
-		lines = append(lines, labelStyle.Render("Max Power Limit:")+valueStyle.Render(fmt.Sprintf("%d W", int(m.powerLimit.Data.MaxPower))))
+		lines = append(lines, labelStyle.Render(t("dashboard.power_limit"))+valueStyle.Render(m.units.Power(float64(m.powerLimit.Data.MaxPower))))
 		lines = append(lines, "")
-		lines = append(lines, helpStyle.Render("Press '+' to increase by 50W, '-' to decrease by 50W"))
-		lines = append(lines, helpStyle.Render("Range: 30-800 W"))
+		lines = append(lines, m.button("limit-down", "−"+m.units.Power(limitStep))+" "+m.button("limit-up", "+"+m.units.Power(limitStep)))
+		if m.pendingLimit != 0 {
+			lines = append(lines, "",
+				labelStyle.Render(t("control.new_limit"))+valueStyle.Render(m.units.Power(float64(m.pendingLimit))),
+				m.button("limit-apply", t("control.apply"))+" "+m.button("limit-cancel", t("control.cancel")),
+				helpStyle.Render(t("control.confirm_keys", m.keys.Confirm.Help().Key, m.keys.Cancel.Help().Key)),
+			)
+		}
+		lines = append(lines, "")
+		if m.powerKeysActive() {
+			lines = append(lines, helpStyle.Render(t("control.limit_keys", m.keys.IncreasePwr.Help().Key, m.keys.DecreasePwr.Help().Key, m.units.Power(limitStep))))
+		}
+		lines = append(lines, helpStyle.Render(t("control.range", m.units.Power(minLimit), m.units.Power(maxLimit))))
 	}
 
 	return lipgloss.JoinVertical(lipgloss.Left, lines...)
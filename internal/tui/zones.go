@@ -0,0 +1,115 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// zones maps named regions of the rendered view to screen coordinates for
+// mouse hit testing. Render functions wrap regions with mark, which adds
+// zero-width escape sequences around them; scan locates and removes these
+// from the final view. Model is copied by value, so all copies share one
+// zones through a pointer.
+type zones struct {
+	mu    sync.Mutex
+	ids   map[string]int
+	names []string
+	rects map[string]zoneRect
+}
+
+// zoneRect spans from the start cell to the cell before the end cell, in
+// reading order.
+type zoneRect struct {
+	x0, y0, x1, y1 int
+}
+
+func (r zoneRect) contains(x, y int) bool {
+	if y < r.y0 || y > r.y1 {
+		return false
+	}
+	if y == r.y0 && x < r.x0 {
+		return false
+	}
+	return y != r.y1 || x < r.x1
+}
+
+// zoneMarkerBase keeps zone markers clear of real CSI parameters.
+const zoneMarkerBase = 20000
+
+var zoneMarker = regexp.MustCompile(`\x1b\[(\d+)z`)
+
+func newZones() *zones {
+	return &zones{ids: make(map[string]int), rects: make(map[string]zoneRect)}
+}
+
+// mark wraps s so that scan records its position as zone name.
+func (z *zones) mark(name, s string) string {
+	z.mu.Lock()
+	id, ok := z.ids[name]
+	if !ok {
+		id = len(z.names)
+		z.ids[name] = id
+		z.names = append(z.names, name)
+	}
+	z.mu.Unlock()
+	start := zoneMarkerBase + 2*id
+	return fmt.Sprintf("\x1b[%dz%s\x1b[%dz", start, s, start+1)
+}
+
+// scan records the positions of all marked zones in view and returns view
+// without the markers.
+func (z *zones) scan(view string) string {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	rects := make(map[string]zoneRect)
+	lines := strings.Split(view, "\n")
+	for y, line := range lines {
+		matches := zoneMarker.FindAllStringSubmatchIndex(line, -1)
+		for _, m := range matches {
+			n, _ := strconv.Atoi(line[m[2]:m[3]])
+			n -= zoneMarkerBase
+			if n < 0 || n/2 >= len(z.names) {
+				continue
+			}
+			name := z.names[n/2]
+			x := lipgloss.Width(line[:m[0]])
+			r := rects[name]
+			if n%2 == 0 {
+				r.x0, r.y0 = x, y
+			} else {
+				r.x1, r.y1 = x, y
+			}
+			rects[name] = r
+		}
+		if matches != nil {
+			lines[y] = zoneMarker.ReplaceAllString(line, "")
+		}
+	}
+	z.rects = rects
+	return strings.Join(lines, "\n")
+}
+
+// clear forgets all zone positions.
+func (z *zones) clear() {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.rects = make(map[string]zoneRect)
+}
+
+// at returns the name of the zone at the given cell, or "".
+func (z *zones) at(x, y int) string {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	for name, r := range z.rects {
+		if r.contains(x, y) {
+			return name
+		}
+	}
+	return ""
+}
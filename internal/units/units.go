@@ -0,0 +1,177 @@
+// Package units formats readings for people: power and energy in a
+// readable unit, numbers with the separators of a locale and times on a
+// 12- or 24-hour clock. Machine formats such as perfdata, JSON and line
+// protocols do not use it.
+package units
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formatter formats values; New returns one for a locale.
+type Formatter struct {
+	// Decimal separates the fraction, Group the thousands.
+	Decimal, Group string
+	// Precision is the number of decimals of values other than watts; a
+	// negative precision uses the default of each unit.
+	Precision int
+	// Hour12 shows times on a 12-hour clock.
+	Hour12 bool
+	// Scale switches to kW from 1000 W and to MWh from 1000 kWh.
+	Scale bool
+}
+
+// Default decimals per unit.
+const (
+	kilowattDecimals     = 2
+	kilowattHourDecimals = 3
+	megawattHourDecimals = 3
+	kilogramDecimals     = 2
+)
+
+// decimalComma lists languages writing 1.234,5 and spaceGroup those
+// grouping digits with a space instead of a point.
+var (
+	decimalComma = map[string]bool{
+		"de": true, "nl": true, "fr": true, "it": true, "es": true, "pt": true, "da": true, "sv": true,
+		"nb": true, "no": true, "fi": true, "pl": true, "cs": true, "sk": true, "ru": true, "tr": true,
+	}
+	spaceGroup = map[string]bool{
+		"fr": true, "sv": true, "nb": true, "no": true, "fi": true, "pl": true, "cs": true, "sk": true, "ru": true,
+	}
+	// hour12 lists regions that use a 12-hour clock.
+	hour12 = map[string]bool{"US": true, "CA": true, "AU": true, "NZ": true, "PH": true, "IN": true}
+)
+
+// New returns a formatter for a locale such as "de", "de_DE.UTF-8" or
+// "en-US", with scaling enabled and the default precision. An empty
+// locale is taken from LC_ALL, LC_NUMERIC and LC_TIME, or LANG.
+func New(locale string) Formatter {
+	numeric, clock := locale, locale
+	if locale == "" {
+		numeric, clock = env("LC_NUMERIC"), env("LC_TIME")
+	}
+	f := Formatter{Decimal: ".", Group: ",", Precision: -1, Scale: true}
+	if lang, _ := parse(numeric); decimalComma[lang] {
+		f.Decimal, f.Group = ",", "."
+		if spaceGroup[lang] {
+			f.Group = " "
+		}
+	}
+	lang, region := parse(clock)
+	f.Hour12 = lang == "en" && hour12[region]
+	return f
+}
+
+// env returns the locale of a category the way setlocale resolves it.
+func env(category string) string {
+	for _, name := range []string{"LC_ALL", category, "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// parse splits a locale into its lower-case language and upper-case region,
+// dropping the encoding and modifier.
+func parse(locale string) (lang, region string) {
+	locale, _, _ = strings.Cut(locale, ".")
+	locale, _, _ = strings.Cut(locale, "@")
+	lang, region, _ = strings.Cut(strings.ReplaceAll(locale, "-", "_"), "_")
+	return strings.ToLower(lang), strings.ToUpper(region)
+}
+
+// Number formats v with the given number of decimals, or the formatter's
+// precision if it is set.
+func (f Formatter) Number(v float64, decimals int) string {
+	if f.Precision >= 0 {
+		decimals = f.Precision
+	}
+	return f.number(v, decimals)
+}
+
+func (f Formatter) number(v float64, decimals int) string {
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	if f.Group != "" {
+		var b strings.Builder
+		for i, d := range whole {
+			if i > 0 && (len(whole)-i)%3 == 0 {
+				b.WriteString(f.Group)
+			}
+			b.WriteRune(d)
+		}
+		whole = b.String()
+	}
+	if frac == "" {
+		return sign + whole
+	}
+	decimal := f.Decimal
+	if decimal == "" {
+		decimal = "."
+	}
+	return sign + whole + decimal + frac
+}
+
+// PowerParts formats watts and returns the number and the unit separately,
+// for layouts that style them differently.
+func (f Formatter) PowerParts(watts float64) (value, unit string) {
+	if f.Scale && math.Abs(watts) >= 1000 {
+		return f.Number(watts/1000, kilowattDecimals), "kW"
+	}
+	return f.number(math.Round(watts), 0), "W"
+}
+
+// Power formats watts, e.g. "734 W" or "1,25 kW".
+func (f Formatter) Power(watts float64) string {
+	value, unit := f.PowerParts(watts)
+	return value + " " + unit
+}
+
+// EnergyParts formats kilowatt hours and returns the number and the unit
+// separately.
+func (f Formatter) EnergyParts(kwh float64) (value, unit string) {
+	if f.Scale && math.Abs(kwh) >= 1000 {
+		return f.Number(kwh/1000, megawattHourDecimals), "MWh"
+	}
+	return f.Number(kwh, kilowattHourDecimals), "kWh"
+}
+
+// Energy formats kilowatt hours, e.g. "1,234 kWh" or "1,052 MWh".
+func (f Formatter) Energy(kwh float64) string {
+	value, unit := f.EnergyParts(kwh)
+	return value + " " + unit
+}
+
+// Mass formats kilograms, switching to tonnes from 1000 kg.
+func (f Formatter) Mass(kg float64) string {
+	if f.Scale && math.Abs(kg) >= 1000 {
+		return f.Number(kg/1000, kilogramDecimals) + " t"
+	}
+	return f.Number(kg, kilogramDecimals) + " kg"
+}
+
+// Time formats a time of day with seconds.
+func (f Formatter) Time(t time.Time) string {
+	if f.Hour12 {
+		return t.Format("3:04:05 PM")
+	}
+	return t.Format("15:04:05")
+}
+
+// Clock formats a time of day without seconds.
+func (f Formatter) Clock(t time.Time) string {
+	if f.Hour12 {
+		return t.Format("3:04 PM")
+	}
+	return t.Format("15:04")
+}
@@ -0,0 +1,322 @@
+"use strict";
+
+// Mirrors the TUI: the same four views and the same 50 W limit steps. Unlike
+// the TUI's keys and buttons, every write is confirmed before it reaches the
+// device.
+
+const LIMIT_STEP = 50;
+const CHART_WINDOW = 60 * 60 * 1000;
+
+const state = {
+  token: localStorage.getItem("ez1-token") || "",
+  minPower: 30,
+  maxPower: 800,
+  limit: null,
+  status: null,
+  samples: [],
+  source: null,
+};
+
+const $ = (id) => document.getElementById(id);
+
+function showError(msg) {
+  const el = $("error");
+  el.textContent = msg || "";
+  el.hidden = !msg;
+}
+
+function askToken() {
+  const token = prompt("Access token for this gateway:", state.token);
+  if (token === null) {
+    return false;
+  }
+  state.token = token.trim();
+  localStorage.setItem("ez1-token", state.token);
+  return true;
+}
+
+async function api(method, path, body) {
+  for (;;) {
+    const headers = {};
+    if (state.token) {
+      headers["Authorization"] = "Bearer " + state.token;
+    }
+    if (body !== undefined) {
+      headers["Content-Type"] = "application/json";
+    }
+    const res = await fetch(path, {
+      method,
+      headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    if (res.status === 401 && askToken()) {
+      continue;
+    }
+    const data = await res.json().catch(() => ({}));
+    if (!res.ok) {
+      throw new Error(data.error || res.statusText);
+    }
+    return data;
+  }
+}
+
+// Views
+
+function selectView(name) {
+  document.querySelectorAll("#tabs button").forEach((b) => {
+    b.classList.toggle("active", b.dataset.view === name);
+  });
+  document.querySelectorAll(".view").forEach((v) => {
+    v.classList.toggle("active", v.id === name);
+  });
+  if (name === "dashboard") {
+    drawChart();
+  }
+}
+
+function renderStatus(s) {
+  $("power").textContent = s.power_w.total.toFixed(0) + " W";
+  $("energy-today").textContent = s.energy_today_kwh.total.toFixed(2) + " kWh";
+  $("energy-lifetime").textContent = s.energy_lifetime_kwh.total.toFixed(2) + " kWh";
+  $("updated").textContent = new Date(s.updated_at).toLocaleTimeString();
+}
+
+function renderDevice(d) {
+  $("device-id").textContent = d.device_id;
+  $("firmware").textContent = d.firmware;
+  $("ip").textContent = d.ip_addr;
+  $("ssid").textContent = d.ssid;
+  $("min-power").textContent = d.min_power_w + " W";
+  $("max-power").textContent = d.max_power_w + " W";
+  if (d.min_power_w > 0 && d.max_power_w > d.min_power_w) {
+    state.minPower = d.min_power_w;
+    state.maxPower = d.max_power_w;
+  }
+  $("limit-range").textContent = `Range: ${state.minPower}–${state.maxPower} W`;
+  $("limit-input").min = state.minPower;
+  $("limit-input").max = state.maxPower;
+}
+
+// active is null while the inverter does not report the flag.
+function renderAlarm(name, active) {
+  const el = document.querySelector(`[data-alarm="${name}"]`);
+  el.textContent = active === null ? "Unknown" : active ? "Alarm" : "Normal";
+  el.classList.toggle("alarm", active === true);
+  el.classList.toggle("ok", active === false);
+}
+
+function renderAlarms(a) {
+  for (const [name, active] of Object.entries(a)) {
+    renderAlarm(name, active);
+  }
+}
+
+function renderPower(status) {
+  state.status = status;
+  $("status").textContent = status;
+  $("dash-status").textContent = status;
+  $("power-on").disabled = status === "ON";
+  $("power-off").disabled = status === "OFF";
+}
+
+function renderLimit(watts) {
+  state.limit = watts;
+  $("limit").textContent = watts + " W";
+  $("dash-limit").textContent = watts + " W";
+  $("limit-input").value = watts;
+}
+
+// Chart
+
+function addSample(time, watts) {
+  state.samples.push({ t: time.getTime(), w: watts });
+  const cutoff = Date.now() - CHART_WINDOW;
+  while (state.samples.length && state.samples[0].t < cutoff) {
+    state.samples.shift();
+  }
+}
+
+function drawChart() {
+  const canvas = $("chart");
+  const ctx = canvas.getContext("2d");
+  const w = canvas.width;
+  const h = canvas.height;
+  const pad = 30;
+  ctx.clearRect(0, 0, w, h);
+
+  const max = Math.max(state.maxPower, ...state.samples.map((s) => s.w));
+  const now = Date.now();
+  const x = (t) => pad + ((t - (now - CHART_WINDOW)) / CHART_WINDOW) * (w - 2 * pad);
+  const y = (v) => h - pad - (v / max) * (h - 2 * pad);
+
+  ctx.strokeStyle = "#333";
+  ctx.fillStyle = "#666";
+  ctx.font = "11px monospace";
+  for (const v of [0, max / 2, max]) {
+    ctx.beginPath();
+    ctx.moveTo(pad, y(v));
+    ctx.lineTo(w - pad, y(v));
+    ctx.stroke();
+    ctx.fillText(v.toFixed(0), 2, y(v) + 4);
+  }
+  ctx.fillText("-60 min", pad, h - 10);
+  ctx.fillText("now", w - pad - 20, h - 10);
+
+  if (state.limit !== null) {
+    ctx.strokeStyle = "#FF6600";
+    ctx.setLineDash([4, 4]);
+    ctx.beginPath();
+    ctx.moveTo(pad, y(state.limit));
+    ctx.lineTo(w - pad, y(state.limit));
+    ctx.stroke();
+    ctx.setLineDash([]);
+  }
+
+  if (state.samples.length < 2) {
+    return;
+  }
+  ctx.strokeStyle = "#7D56F4";
+  ctx.lineWidth = 2;
+  ctx.beginPath();
+  state.samples.forEach((s, i) => {
+    if (i === 0) {
+      ctx.moveTo(x(s.t), y(s.w));
+    } else {
+      ctx.lineTo(x(s.t), y(s.w));
+    }
+  });
+  ctx.stroke();
+  ctx.lineWidth = 1;
+}
+
+// Live stream
+
+function setConnection(online) {
+  const el = $("connection");
+  el.textContent = online ? "live" : "reconnecting…";
+  el.classList.toggle("online", online);
+  el.classList.toggle("offline", !online);
+}
+
+function connect() {
+  const params = new URLSearchParams();
+  params.set("since", new Date(Date.now() - CHART_WINDOW).toISOString());
+  if (state.token) {
+    params.set("token", state.token);
+  }
+  const source = new EventSource("/v1/events?" + params);
+  state.source = source;
+
+  source.onopen = () => setConnection(true);
+  source.onerror = () => setConnection(false);
+
+  source.addEventListener("sample", (msg) => {
+    const e = JSON.parse(msg.data);
+    addSample(new Date(e.time), e.data.power_w.total);
+    renderStatus(e.data);
+    drawChart();
+  });
+  source.addEventListener("alarm", (msg) => {
+    const e = JSON.parse(msg.data);
+    renderAlarm(e.data.alarm, e.data.active);
+  });
+  source.addEventListener("control", (msg) => {
+    const e = JSON.parse(msg.data);
+    if (e.data.status) {
+      renderPower(e.data.status);
+    }
+    if (e.data.max_power_w !== undefined) {
+      renderLimit(e.data.max_power_w);
+      drawChart();
+    }
+  });
+  source.addEventListener("overflow", () => {
+    // The browser reconnects on its own and resumes from Last-Event-ID.
+    setConnection(false);
+  });
+}
+
+// Power control
+
+async function setLimit(watts) {
+  if (!Number.isInteger(watts) || watts < state.minPower || watts > state.maxPower) {
+    showError(`Limit must be between ${state.minPower} and ${state.maxPower} W`);
+    return;
+  }
+  if (watts === state.limit) {
+    return;
+  }
+  if (!confirm(`Set maximum power limit to ${watts} W?`)) {
+    return;
+  }
+  try {
+    const res = await api("PUT", "/v1/limit", { max_power_w: watts });
+    renderLimit(res.max_power_w);
+    showError("");
+  } catch (err) {
+    showError("Failed to set power limit: " + err.message);
+  }
+}
+
+async function setPower(status) {
+  const question = status === "OFF"
+    ? "Turn the inverter OFF? It stops feeding power until switched back on."
+    : "Turn the inverter ON?";
+  if (!confirm(question)) {
+    return;
+  }
+  try {
+    const res = await api("PUT", "/v1/power", { status });
+    renderPower(res.status);
+    showError("");
+  } catch (err) {
+    showError("Failed to set power status: " + err.message);
+  }
+}
+
+function clampStep(delta) {
+  const base = state.limit ?? state.maxPower;
+  return Math.min(state.maxPower, Math.max(state.minPower, base + delta));
+}
+
+async function loadAll() {
+  const tasks = [
+    api("GET", "/v1/status").then(renderStatus),
+    api("GET", "/v1/device").then(renderDevice),
+    api("GET", "/v1/alarms").then(renderAlarms),
+    api("GET", "/v1/power").then((p) => renderPower(p.status)),
+    api("GET", "/v1/limit").then((l) => renderLimit(l.max_power_w)),
+  ];
+  const results = await Promise.allSettled(tasks);
+  const failed = results.find((r) => r.status === "rejected");
+  showError(failed ? "Failed to load data: " + failed.reason.message : "");
+}
+
+function init() {
+  document.querySelectorAll("#tabs button").forEach((b) => {
+    b.addEventListener("click", () => selectView(b.dataset.view));
+  });
+  document.addEventListener("keydown", (e) => {
+    if (e.target.tagName === "INPUT") {
+      return;
+    }
+    const views = ["dashboard", "device", "alarms", "control"];
+    const n = parseInt(e.key, 10);
+    if (n >= 1 && n <= views.length) {
+      selectView(views[n - 1]);
+    }
+  });
+
+  $("power-on").addEventListener("click", () => setPower("ON"));
+  $("power-off").addEventListener("click", () => setPower("OFF"));
+  $("limit-down").addEventListener("click", () => setLimit(clampStep(-LIMIT_STEP)));
+  $("limit-up").addEventListener("click", () => setLimit(clampStep(LIMIT_STEP)));
+  $("limit-set").addEventListener("click", () => setLimit(Number($("limit-input").value)));
+
+  loadAll().then(() => {
+    drawChart();
+    connect();
+  });
+}
+
+init();
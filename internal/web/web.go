@@ -0,0 +1,20 @@
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// Handler serves the single-page dashboard. It talks to the gateway's /v1 API
+// and event stream, so it must be mounted on the same server.
+func Handler() http.Handler {
+	root, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServerFS(root)
+}
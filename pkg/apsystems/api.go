@@ -2,17 +2,23 @@ package apsystems
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 )
 
+// ErrInvalidArgument is wrapped by errors for arguments rejected before any
+// request is sent to the device.
+var ErrInvalidArgument = errors.New("invalid argument")
+
 func (c *Client) GetDeviceInfo(ctx context.Context) (*DeviceInfo, error) {
 	var info DeviceInfo
 	err := c.doRequest(ctx, http.MethodGet, "/getDeviceInfo", nil, &info)
 	if err != nil {
 		return nil, fmt.Errorf("get device info: %w", err)
 	}
+	c.detectFirmware(info.Data.Firmware)
 	return &info, nil
 }
 
@@ -45,7 +51,7 @@ func (c *Client) GetMaxPower(ctx context.Context) (*PowerLimit, error) {
 
 func (c *Client) SetMaxPower(ctx context.Context, watts int) error {
 	if watts < 30 || watts > 800 {
-		return fmt.Errorf("power must be between 30 and 800 watts, got %d", watts)
+		return fmt.Errorf("%w: power must be between 30 and 800 watts, got %d", ErrInvalidArgument, watts)
 	}
 
 	var resp PowerLimit
@@ -74,7 +80,7 @@ func (c *Client) SetDevicePowerStatus(ctx context.Context, status string) error
 	case "OFF":
 		statusCode = 1
 	default:
-		return fmt.Errorf("invalid status: %s (must be ON, OFF)", status)
+		return fmt.Errorf("%w: invalid status: %s (must be ON, OFF)", ErrInvalidArgument, status)
 	}
 
 	var resp PowerStatus
@@ -91,17 +97,23 @@ func (c *Client) GetStatistics(ctx context.Context) (*Statistics, error) {
 	if err != nil {
 		return nil, err
 	}
+	return NewStatistics(output, time.Now()), nil
+}
 
+// NewStatistics aggregates output data from both PV inputs.
+func NewStatistics(output *OutputData, at time.Time) *Statistics {
+	d := output.Data
 	return &Statistics{
-		Power1:              output.Data.P1,
-		EnergyToday1:        output.Data.E1,
-		EnergyLifetime1:     output.Data.Te1,
-		Power2:              output.Data.P2,
-		EnergyToday2:        output.Data.E2,
-		EnergyLifetime2:     output.Data.Te2,
-		TotalPower:          output.Data.P1 + output.Data.P2,
-		TotalEnergyToday:    output.Data.E1 + output.Data.E2,
-		TotalEnergyLifetime: output.Data.Te1 + output.Data.Te2,
-		LastUpdate:          time.Now(),
-	}, nil
+		Power1:              int(d.P1),
+		EnergyToday1:        float64(d.E1),
+		EnergyLifetime1:     float64(d.Te1),
+		Power2:              int(d.P2),
+		EnergyToday2:        float64(d.E2),
+		EnergyLifetime2:     float64(d.Te2),
+		TotalPower:          int(d.P1 + d.P2),
+		TotalEnergyToday:    float64(d.E1 + d.E2),
+		TotalEnergyLifetime: float64(d.Te1 + d.Te2),
+		LastUpdate:          at,
+		Validity:            output.Validity,
+	}
 }
@@ -4,24 +4,79 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sync/atomic"
 	"time"
 )
 
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	token      string
+	health     *healthTracker
+	profile    atomic.Pointer[Profile]
+	pinned     bool
 }
 
-func NewClient(host string, port int) *Client {
-	return &Client{
+// ClientOption configures optional Client behaviour.
+type ClientOption func(*Client)
+
+// WithToken sends a bearer token with every request, e.g. when talking to an
+// ez1-tui gateway instead of the device itself.
+func WithToken(token string) ClientOption {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithHTTPClient replaces the default HTTP client.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithFirmware decodes responses for the given firmware version instead of
+// the one reported by the device.
+func WithFirmware(version string) ClientOption {
+	return func(c *Client) {
+		p := ProfileFor(version)
+		c.profile.Store(&p)
+		c.pinned = true
+	}
+}
+
+func NewClient(host string, port int, opts ...ClientOption) *Client {
+	c := &Client{
 		baseURL: fmt.Sprintf("http://%s:%d", host, port),
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		health: newHealthTracker(),
 	}
+	c.profile.Store(&profiles[0])
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Profile returns the firmware profile responses are decoded with.
+func (c *Client) Profile() Profile {
+	return *c.profile.Load()
+}
+
+// detectFirmware selects the profile for the firmware reported by the
+// device, unless one was set with WithFirmware.
+func (c *Client) detectFirmware(firmware string) {
+	if c.pinned || firmware == "" {
+		return
+	}
+	p := ProfileFor(firmware)
+	c.profile.Store(&p)
 }
 
 func (c *Client) doRequest(ctx context.Context, method, endpoint string, body interface{}, result interface{}) error {
@@ -42,23 +97,39 @@ func (c *Client) doRequest(ctx context.Context, method, endpoint string, body in
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
 
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			c.health.record(endpoint, start, classify(err), err)
+		}
 		return fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+		err := fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+		c.health.record(endpoint, start, FailureHTTP, err)
+		return err
 	}
 
 	if result != nil {
-		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
-			return fmt.Errorf("failed to decode response: %w", err)
+		data, err := io.ReadAll(resp.Body)
+		if err == nil {
+			err = c.profile.Load().Decode(data, result)
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to decode response: %w", err)
+			c.health.record(endpoint, start, FailureResponse, err)
+			return err
 		}
 	}
 
+	c.health.record(endpoint, start, FailureNone, nil)
 	return nil
 }
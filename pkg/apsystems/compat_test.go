@@ -0,0 +1,89 @@
+package apsystems
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestFirmwareCorpus decodes the recorded responses in testdata/firmware
+// with the profile ProfileFor selects for each directory and compares the
+// re-encoded values with expected.json.
+func TestFirmwareCorpus(t *testing.T) {
+	type response interface{ Invalid() []string }
+	endpoints := map[string]func() response{
+		"getDeviceInfo": func() response { return &DeviceInfo{} },
+		"getOutputData": func() response { return &OutputData{} },
+		"getAlarm":      func() response { return &AlarmInfo{} },
+		"getOnOff":      func() response { return &PowerStatus{} },
+		"getMaxPower":   func() response { return &PowerLimit{} },
+	}
+
+	data, err := os.ReadFile("testdata/firmware/expected.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected map[string]any
+	if err := json.Unmarshal(data, &expected); err != nil {
+		t.Fatal(err)
+	}
+
+	dirs, err := os.ReadDir("testdata/firmware")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		t.Run(dir.Name(), func(t *testing.T) {
+			profile := ProfileFor(dir.Name())
+			for name, newValue := range endpoints {
+				body, err := os.ReadFile(filepath.Join("testdata/firmware", dir.Name(), name+".json"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				v := newValue()
+				if err := profile.Decode(body, v); err != nil {
+					t.Errorf("%s: decode: %v", name, err)
+					continue
+				}
+				if invalid := v.Invalid(); len(invalid) > 0 {
+					t.Errorf("%s: invalid fields %v", name, invalid)
+				}
+
+				encoded, err := json.Marshal(v)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got any
+				if err := json.Unmarshal(encoded, &got); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, expected[name]) {
+					t.Errorf("%s with profile %s:\n got %s\nwant %v", name, profile.Name, encoded, expected[name])
+				}
+			}
+		})
+	}
+}
+
+func TestProfileFor(t *testing.T) {
+	tests := []struct {
+		firmware string
+		want     string
+	}{
+		{"EZ1 1.6.0", "ez1"},
+		{"EZ1 1.7.5", "ez1"},
+		{"1.12", "ez1"},
+		{"", "ez1"},
+		{"unknown", "ez1"},
+	}
+	for _, tt := range tests {
+		if got := ProfileFor(tt.firmware).Name; got != tt.want {
+			t.Errorf("ProfileFor(%q) = %s, want %s", tt.firmware, got, tt.want)
+		}
+	}
+}
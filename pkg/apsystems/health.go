@@ -0,0 +1,314 @@
+package apsystems
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FailureKind classifies why a request failed, to tell connectivity problems
+// from problems of the inverter itself.
+type FailureKind int
+
+const (
+	FailureNone FailureKind = iota
+	// FailureNetwork means no connection could be made: Wi-Fi, DNS or
+	// routing problems, or the inverter is powered down.
+	FailureNetwork
+	// FailureTimeout means the request got no answer in time.
+	FailureTimeout
+	// FailureHTTP means the inverter answered with an error status.
+	FailureHTTP
+	// FailureResponse means the answer could not be decoded.
+	FailureResponse
+)
+
+func (k FailureKind) String() string {
+	switch k {
+	case FailureNetwork:
+		return "network"
+	case FailureTimeout:
+		return "timeout"
+	case FailureHTTP:
+		return "http"
+	case FailureResponse:
+		return "response"
+	default:
+		return "none"
+	}
+}
+
+// ConnectionStatus summarizes the health of the connection.
+type ConnectionStatus int
+
+const (
+	StatusUnknown ConnectionStatus = iota
+	StatusOnline
+	StatusDegraded
+	StatusOffline
+)
+
+func (s ConnectionStatus) String() string {
+	switch s {
+	case StatusOnline:
+		return "online"
+	case StatusDegraded:
+		return "degraded"
+	case StatusOffline:
+		return "offline"
+	default:
+		return "unknown"
+	}
+}
+
+// Problem is the likely cause of a connection problem, as described by
+// Health.Diagnosis.
+type Problem int
+
+const (
+	ProblemNone Problem = iota
+	// ProblemNetwork means the inverter cannot be reached.
+	ProblemNetwork
+	// ProblemTimeout means requests time out.
+	ProblemTimeout
+	// ProblemDevice means the inverter answers with errors.
+	ProblemDevice
+	// ProblemSlow means responses are slow, see Health.Slowest.
+	ProblemSlow
+)
+
+func (p Problem) String() string {
+	switch p {
+	case ProblemNetwork:
+		return "network"
+	case ProblemTimeout:
+		return "timeout"
+	case ProblemDevice:
+		return "device"
+	case ProblemSlow:
+		return "slow"
+	default:
+		return "none"
+	}
+}
+
+// offlineAfter is the number of failed requests in a row after which the
+// device is considered offline.
+const offlineAfter = 3
+
+// slowLatency is the 90th percentile latency above which responses count as
+// slow, which usually points to a weak Wi-Fi signal.
+const slowLatency = time.Second
+
+// latencyWindow is the number of recent successful requests per endpoint
+// that latency percentiles are computed over.
+const latencyWindow = 100
+
+// EndpointHealth holds request statistics for one API endpoint. Latency
+// percentiles cover the most recent successful requests.
+type EndpointHealth struct {
+	Endpoint            string
+	Requests            int
+	Failures            int
+	ConsecutiveFailures int
+	LastSuccess         time.Time
+	LastFailure         time.Time
+	LastError           string
+	LastFailureKind     FailureKind
+	P50, P90, P99       time.Duration
+}
+
+// SuccessRate returns the share of successful requests between 0 and 1.
+func (e EndpointHealth) SuccessRate() float64 {
+	if e.Requests == 0 {
+		return 0
+	}
+	return float64(e.Requests-e.Failures) / float64(e.Requests)
+}
+
+// Health is a snapshot of the connection statistics of a Client.
+type Health struct {
+	Endpoints []EndpointHealth // sorted by endpoint
+	// ConsecutiveFailures counts failed requests in a row across all
+	// endpoints.
+	ConsecutiveFailures int
+	LastSuccess         time.Time
+	LastFailureKind     FailureKind
+}
+
+// Status returns online, degraded (recent failures or slow responses) or
+// offline.
+func (h Health) Status() ConnectionStatus {
+	if len(h.Endpoints) == 0 {
+		return StatusUnknown
+	}
+	if h.ConsecutiveFailures >= offlineAfter {
+		return StatusOffline
+	}
+	if _, slow := h.Slowest(); h.ConsecutiveFailures > 0 || slow {
+		return StatusDegraded
+	}
+	return StatusOnline
+}
+
+// Latency returns the highest median latency over all endpoints.
+func (h Health) Latency() time.Duration {
+	var d time.Duration
+	for _, e := range h.Endpoints {
+		d = max(d, e.P50)
+	}
+	return d
+}
+
+// Problem returns the likely cause of a problem, or ProblemNone if the
+// connection is healthy.
+func (h Health) Problem() Problem {
+	switch h.Status() {
+	case StatusOffline, StatusDegraded:
+		switch h.LastFailureKind {
+		case FailureNetwork:
+			return ProblemNetwork
+		case FailureTimeout:
+			return ProblemTimeout
+		case FailureHTTP, FailureResponse:
+			return ProblemDevice
+		}
+		if _, slow := h.Slowest(); slow {
+			return ProblemSlow
+		}
+	}
+	return ProblemNone
+}
+
+// Diagnosis returns a short hint on the likely cause of a problem, or an
+// empty string if the connection is healthy.
+func (h Health) Diagnosis() string {
+	switch h.Problem() {
+	case ProblemNetwork:
+		return "Cannot connect: check the Wi-Fi connection, the IP address, and whether the inverter has power (it shuts down without sun)"
+	case ProblemTimeout:
+		return "Requests time out: the Wi-Fi signal is weak or the inverter is overloaded"
+	case ProblemDevice:
+		return "The inverter answers with errors: the network is fine, check local mode and the firmware version"
+	case ProblemSlow:
+		e, _ := h.Slowest()
+		return fmt.Sprintf("Slow responses (%s on %s): the Wi-Fi signal is probably weak", e.P90.Round(time.Millisecond), e.Endpoint)
+	}
+	return ""
+}
+
+// Slowest returns the endpoint with the highest 90th percentile latency and
+// whether it is slow enough to point to a weak Wi-Fi signal.
+func (h Health) Slowest() (EndpointHealth, bool) {
+	var slowest EndpointHealth
+	for _, e := range h.Endpoints {
+		if e.P90 > slowest.P90 {
+			slowest = e
+		}
+	}
+	return slowest, slowest.P90 > slowLatency
+}
+
+type endpointStats struct {
+	EndpointHealth
+	latencies []time.Duration // ring buffer
+	next      int
+}
+
+type healthTracker struct {
+	mu          sync.Mutex
+	endpoints   map[string]*endpointStats
+	consecutive int
+	lastSuccess time.Time
+	lastKind    FailureKind
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{endpoints: make(map[string]*endpointStats)}
+}
+
+func (t *healthTracker) record(endpoint string, start time.Time, kind FailureKind, err error) {
+	if path, _, ok := strings.Cut(endpoint, "?"); ok {
+		endpoint = path
+	}
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.endpoints[endpoint]
+	if !ok {
+		s = &endpointStats{EndpointHealth: EndpointHealth{Endpoint: endpoint}}
+		t.endpoints[endpoint] = s
+	}
+	s.Requests++
+	if err != nil {
+		s.Failures++
+		s.ConsecutiveFailures++
+		s.LastFailure = now
+		s.LastError = err.Error()
+		s.LastFailureKind = kind
+		t.consecutive++
+		t.lastKind = kind
+		return
+	}
+	s.ConsecutiveFailures = 0
+	s.LastSuccess = now
+	t.consecutive = 0
+	t.lastSuccess = now
+
+	if len(s.latencies) < latencyWindow {
+		s.latencies = append(s.latencies, now.Sub(start))
+	} else {
+		s.latencies[s.next] = now.Sub(start)
+		s.next = (s.next + 1) % latencyWindow
+	}
+}
+
+func (t *healthTracker) snapshot() Health {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := Health{
+		ConsecutiveFailures: t.consecutive,
+		LastSuccess:         t.lastSuccess,
+		LastFailureKind:     t.lastKind,
+	}
+	for _, s := range t.endpoints {
+		e := s.EndpointHealth
+		if len(s.latencies) > 0 {
+			sorted := slices.Clone(s.latencies)
+			slices.Sort(sorted)
+			e.P50 = percentile(sorted, 50)
+			e.P90 = percentile(sorted, 90)
+			e.P99 = percentile(sorted, 99)
+		}
+		h.Endpoints = append(h.Endpoints, e)
+	}
+	slices.SortFunc(h.Endpoints, func(a, b EndpointHealth) int { return strings.Compare(a.Endpoint, b.Endpoint) })
+	return h
+}
+
+// percentile returns the p-th percentile of sorted using the nearest rank.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// classify returns the failure kind of a transport error.
+func classify(err error) FailureKind {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return FailureTimeout
+	}
+	return FailureNetwork
+}
+
+// Health returns the request statistics collected so far.
+func (c *Client) Health() Health {
+	return c.health.snapshot()
+}
@@ -0,0 +1,245 @@
+package apsystems
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exchange is a recorded request and its response.
+type Exchange struct {
+	Time       time.Time       `json:"time"`
+	Method     string          `json:"method"`
+	Path       string          `json:"path"` // including the query
+	Status     int             `json:"status,omitempty"`
+	DurationMS float64         `json:"duration_ms"`
+	Body       json.RawMessage `json:"body,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// Recorder is an http.RoundTripper that writes every exchange as a line of
+// JSON. Headers are not recorded, so recordings do not contain tokens.
+type Recorder struct {
+	next http.RoundTripper
+	mu   sync.Mutex
+	enc  *json.Encoder
+}
+
+// NewRecorder records exchanges made through next, or through
+// http.DefaultTransport if next is nil, to w.
+func NewRecorder(w io.Writer, next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{next: next, enc: json.NewEncoder(w)}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := r.next.RoundTrip(req)
+
+	x := Exchange{Time: start, Method: req.Method, Path: req.URL.RequestURI()}
+	if err == nil {
+		var body []byte
+		body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		x.Status = resp.StatusCode
+		x.Body = encodeBody(body)
+	}
+	x.DurationMS = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		x.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if encErr := r.enc.Encode(x); encErr != nil && err == nil {
+		err = fmt.Errorf("record: %w", encErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// encodeBody keeps JSON bodies as they are and stores anything else as a
+// JSON string.
+func encodeBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return body
+	}
+	quoted, _ := json.Marshal(string(body))
+	return quoted
+}
+
+func decodeBody(raw json.RawMessage) []byte {
+	var s string
+	if len(raw) > 0 && raw[0] == '"' && json.Unmarshal(raw, &s) == nil {
+		return []byte(s)
+	}
+	return raw
+}
+
+// ReadRecording reads exchanges written by a Recorder.
+func ReadRecording(r io.Reader) ([]Exchange, error) {
+	var exchanges []Exchange
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var x Exchange
+		if err := json.Unmarshal(scanner.Bytes(), &x); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		exchanges = append(exchanges, x)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(exchanges) == 0 {
+		return nil, errors.New("recording is empty")
+	}
+	return exchanges, nil
+}
+
+// Replayer is an http.RoundTripper that simulates a device by playing back a
+// recording. Requests are answered with the most recent recorded response of
+// the same endpoint at the current replay time, which starts at the first
+// exchange and runs speed times faster than real time, starting over at the
+// end. Power limit and on/off changes are kept for the rest of the replay.
+type Replayer struct {
+	byPath     map[string][]Exchange // sorted by time
+	start, end time.Time
+	began      time.Time
+	speed      float64
+
+	mu        sync.Mutex
+	overrides map[string][]byte
+}
+
+// NewReplayer plays back exchanges at the given speed, 1 being real time.
+func NewReplayer(exchanges []Exchange, speed float64) *Replayer {
+	if speed <= 0 {
+		speed = 1
+	}
+	r := &Replayer{
+		byPath:    make(map[string][]Exchange),
+		began:     time.Now(),
+		speed:     speed,
+		overrides: make(map[string][]byte),
+	}
+	for _, x := range exchanges {
+		path, _, _ := strings.Cut(x.Path, "?")
+		r.byPath[path] = append(r.byPath[path], x)
+		if r.start.IsZero() || x.Time.Before(r.start) {
+			r.start = x.Time
+		}
+		if x.Time.After(r.end) {
+			r.end = x.Time
+		}
+	}
+	for _, xs := range r.byPath {
+		slices.SortStableFunc(xs, func(a, b Exchange) int { return a.Time.Compare(b.Time) })
+	}
+	return r
+}
+
+// Now returns the current replay time.
+func (r *Replayer) Now() time.Time {
+	elapsed := time.Duration(float64(time.Since(r.began)) * r.speed)
+	if span := r.end.Sub(r.start); span > 0 {
+		elapsed %= span
+	}
+	return r.start.Add(elapsed)
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	path := req.URL.Path
+
+	switch path {
+	case "/setMaxPower":
+		watts, err := strconv.Atoi(req.URL.Query().Get("p"))
+		if err != nil {
+			return response(req, http.StatusBadRequest, []byte(err.Error())), nil
+		}
+		return r.override(req, "/getMaxPower", fmt.Sprintf(`{"data":{"maxPower":"%d"},"message":"SUCCESS"}`, watts)), nil
+	case "/setOnOff":
+		status := req.URL.Query().Get("status")
+		if status != "0" && status != "1" {
+			return response(req, http.StatusBadRequest, []byte("invalid status")), nil
+		}
+		return r.override(req, "/getOnOff", fmt.Sprintf(`{"data":{"status":"%s"},"message":"SUCCESS"}`, status)), nil
+	}
+
+	r.mu.Lock()
+	body, overridden := r.overrides[path]
+	r.mu.Unlock()
+	if overridden {
+		return response(req, http.StatusOK, body), nil
+	}
+
+	xs := r.byPath[path]
+	if len(xs) == 0 {
+		return response(req, http.StatusNotFound, []byte("not recorded: "+path)), nil
+	}
+	now := r.Now()
+	i, _ := slices.BinarySearchFunc(xs, now, func(x Exchange, t time.Time) int {
+		if x.Time.After(t) {
+			return 1
+		}
+		return -1
+	})
+	x := xs[max(i-1, 0)]
+
+	delay := time.Duration(x.DurationMS * float64(time.Millisecond) / r.speed)
+	select {
+	case <-time.After(delay):
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	if x.Error != "" {
+		return nil, errors.New(x.Error)
+	}
+	return response(req, x.Status, decodeBody(x.Body)), nil
+}
+
+// override answers a write request and makes later reads of path return
+// body.
+func (r *Replayer) override(req *http.Request, path, body string) *http.Response {
+	r.mu.Lock()
+	r.overrides[path] = []byte(body)
+	r.mu.Unlock()
+	return response(req, http.StatusOK, []byte(body))
+}
+
+func response(req *http.Request, status int, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,148 @@
+package apsystems
+
+import (
+	"fmt"
+	"time"
+)
+
+// PollMode describes how a Watcher is currently polling.
+type PollMode int
+
+const (
+	// PollNormal polls every endpoint at its configured interval.
+	PollNormal PollMode = iota
+	// PollBackoff probes the device at growing intervals after it could
+	// not be reached.
+	PollBackoff
+	// PollSleeping pauses polling between sunset and sunrise.
+	PollSleeping
+)
+
+// PollState is the current polling mode. Until is the time of the next
+// probe when backing off, or of sunrise when sleeping.
+type PollState struct {
+	Mode     PollMode
+	Until    time.Time
+	Failures int
+}
+
+func (s PollState) String() string {
+	switch s.Mode {
+	case PollBackoff:
+		return fmt.Sprintf("unreachable, retrying at %s", s.Until.Format("15:04:05"))
+	case PollSleeping:
+		return fmt.Sprintf("sleeping until %s", s.Until.Format("15:04"))
+	default:
+		return "polling"
+	}
+}
+
+// Default backoff while the device is unreachable.
+const (
+	DefaultMinBackoff = 10 * time.Second
+	DefaultMaxBackoff = 5 * time.Minute
+)
+
+// daylightMargin keeps polling a while before sunrise and after sunset, when
+// the inverter starts up or is still feeding in.
+const daylightMargin = 30 * time.Minute
+
+// WithBackoff sets the delay before the first retry after the device could
+// not be reached; it doubles with every further failure up to max.
+func WithBackoff(min, max time.Duration) WatcherOption {
+	return func(w *Watcher) {
+		w.minBackoff, w.maxBackoff = min, max
+	}
+}
+
+// WithDaylight stops polling overnight. Sunrise and sunset are computed for
+// the given coordinates in degrees, north and east positive.
+func WithDaylight(lat, lon float64) WatcherOption {
+	return func(w *Watcher) {
+		w.daylight = &location{lat: lat, lon: lon}
+	}
+}
+
+type location struct {
+	lat, lon float64
+}
+
+// sleepUntil reports whether now is outside the daylight window and if so,
+// when the next window starts.
+func (l *location) sleepUntil(now time.Time) (time.Time, bool) {
+	for day := 0; day < 366; day++ {
+		date := now.AddDate(0, 0, day)
+		rise, set, up, ok := SunTimes(date, l.lat, l.lon)
+		if !ok {
+			if up {
+				// Midnight sun: the window spans the whole day.
+				if day == 0 {
+					return time.Time{}, false
+				}
+				y, m, d := date.Date()
+				return time.Date(y, m, d, 0, 0, 0, 0, now.Location()), true
+			}
+			continue // polar night
+		}
+		start, end := rise.Add(-daylightMargin), set.Add(daylightMargin)
+		if now.Before(start) {
+			return start, true
+		}
+		if now.Before(end) {
+			return time.Time{}, false
+		}
+	}
+	return time.Time{}, false
+}
+
+func (w *Watcher) backoff(failures int) time.Duration {
+	d := w.minBackoff
+	for i := 1; i < failures && d < w.maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, w.maxBackoff)
+}
+
+// State returns the current polling mode.
+func (w *Watcher) State() PollState {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.state
+}
+
+// updateSchedule enters or leaves the overnight sleep and reports whether
+// the watcher just woke up.
+func (w *Watcher) updateSchedule(now time.Time) bool {
+	if w.daylight == nil {
+		return false
+	}
+	wake, asleep := w.daylight.sleepUntil(now)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if asleep {
+		w.state = PollState{Mode: PollSleeping, Until: wake}
+		return false
+	}
+	if w.state.Mode == PollSleeping {
+		w.state = PollState{}
+		return true
+	}
+	return false
+}
+
+// observe records the outcome of a poll; w.mu must be held. It reports
+// whether the device just became reachable again.
+func (w *Watcher) observe(now time.Time, err error) bool {
+	if w.state.Mode == PollSleeping {
+		return false
+	}
+	if err != nil {
+		failures := w.state.Failures + 1
+		w.state = PollState{Mode: PollBackoff, Until: now.Add(w.backoff(failures)), Failures: failures}
+		return false
+	}
+	recovered := w.state.Mode == PollBackoff
+	w.state = PollState{}
+	return recovered
+}
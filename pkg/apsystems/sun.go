@@ -0,0 +1,51 @@
+package apsystems
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	julianUnixEpoch = 2440587.5
+	julian2000      = 2451545.0
+	degrees         = math.Pi / 180
+)
+
+func toJulian(t time.Time) float64 {
+	return float64(t.Unix())/86400 + julianUnixEpoch
+}
+
+func fromJulian(j float64) time.Time {
+	return time.Unix(int64(math.Round((j-julianUnixEpoch)*86400)), 0)
+}
+
+// SunTimes returns sunrise and sunset on the calendar day of t (in t's
+// location) at the given coordinates, in degrees with north and east
+// positive. If the sun does not rise or set that day, ok is false and
+// up reports whether it stays above the horizon.
+func SunTimes(t time.Time, lat, lon float64) (sunrise, sunset time.Time, up, ok bool) {
+	// Sunrise equation as used by NOAA, accurate to about a minute.
+	y, m, d := t.Date()
+	noon := time.Date(y, m, d, 12, 0, 0, 0, time.UTC)
+	n := math.Round(toJulian(noon) - julian2000 + 0.0008)
+
+	meanNoon := n - lon/360
+	anomaly := math.Mod(357.5291+0.98560028*meanNoon, 360)
+	center := 1.9148*math.Sin(anomaly*degrees) + 0.02*math.Sin(2*anomaly*degrees) + 0.0003*math.Sin(3*anomaly*degrees)
+	ecliptic := math.Mod(anomaly+center+180+102.9372, 360)
+	transit := julian2000 + meanNoon + 0.0053*math.Sin(anomaly*degrees) - 0.0069*math.Sin(2*ecliptic*degrees)
+
+	declination := math.Asin(math.Sin(ecliptic*degrees) * math.Sin(23.4397*degrees))
+	cosHour := (math.Sin(-0.833*degrees) - math.Sin(lat*degrees)*math.Sin(declination)) /
+		(math.Cos(lat*degrees) * math.Cos(declination))
+	if cosHour > 1 {
+		return time.Time{}, time.Time{}, false, false
+	}
+	if cosHour < -1 {
+		return time.Time{}, time.Time{}, true, false
+	}
+	hour := math.Acos(cosHour) / degrees
+
+	loc := t.Location()
+	return fromJulian(transit - hour/360).In(loc), fromJulian(transit + hour/360).In(loc), true, true
+}
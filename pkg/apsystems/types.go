@@ -2,38 +2,205 @@ package apsystems
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
-// StringInt is a custom type that handles JSON unmarshaling of integer values
-// that may be represented as either strings ("123") or numbers (123) in API responses.
+// StringInt is an integer that the device may send as a number (123), a
+// string ("123"), or null. Values that are not a number decode as 0 and are
+// reported by the Validity of the response.
 type StringInt int
 
 // UnmarshalJSON implements json.Unmarshaler for StringInt.
-// It attempts to unmarshal the value first as a string, then as an integer.
-// This allows the type to handle both "123" and 123 from the same JSON field.
 func (si *StringInt) UnmarshalJSON(data []byte) error {
-	// Try to unmarshal as string first
-	var s string
-	if err := json.Unmarshal(data, &s); err == nil {
-		val, err := strconv.Atoi(s)
-		if err != nil {
-			return err
-		}
-		*si = StringInt(val)
-		return nil
+	if f, ok := parseNumber(data); ok {
+		*si = StringInt(math.Round(f))
+	}
+	return nil
+}
+
+// StringFloat is the floating point counterpart of StringInt.
+type StringFloat float64
+
+// UnmarshalJSON implements json.Unmarshaler for StringFloat.
+func (sf *StringFloat) UnmarshalJSON(data []byte) error {
+	if f, ok := parseNumber(data); ok {
+		*sf = StringFloat(f)
+	}
+	return nil
+}
+
+// parseNumber parses a JSON number or numeric string.
+func parseNumber(data []byte) (float64, bool) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return 0, false
+	}
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
 	}
+	return 0, false
+}
+
+// PowerState is the on/off state of the inverter as reported by /getOnOff.
+type PowerState int
+
+// PowerUnknown is the state of a missing, null or malformed status, so that
+// it is never mistaken for PowerOn.
+const (
+	PowerUnknown PowerState = -1
+	PowerOn      PowerState = 0
+	PowerOff     PowerState = 1
+)
+
+// Known reports whether the device reported a state.
+func (s PowerState) Known() bool {
+	return s != PowerUnknown
+}
+
+func (s PowerState) String() string {
+	switch s {
+	case PowerUnknown:
+		return "UNKNOWN"
+	case PowerOn:
+		return "ON"
+	case PowerOff:
+		return "OFF"
+	}
+	return fmt.Sprintf("UNKNOWN (%d)", int(s))
+}
+
+// UnmarshalJSON implements json.Unmarshaler for PowerState.
+func (s *PowerState) UnmarshalJSON(data []byte) error {
+	*s = PowerState(decodeState(data))
+	return nil
+}
+
+// AlarmState is the value of a single alarm flag.
+type AlarmState int
+
+// AlarmUnknown is the state of a missing, null or malformed flag, so that it
+// is never mistaken for AlarmOK.
+const (
+	AlarmUnknown AlarmState = -1
+	AlarmOK      AlarmState = 0
+	AlarmActive  AlarmState = 1
+)
+
+// Known reports whether the device reported the flag.
+func (s AlarmState) Known() bool {
+	return s != AlarmUnknown
+}
+
+// Active reports whether the alarm is raised; unexpected values count as
+// raised, an unknown state does not.
+func (s AlarmState) Active() bool {
+	return s != AlarmOK && s != AlarmUnknown
+}
+
+func (s AlarmState) String() string {
+	switch s {
+	case AlarmUnknown:
+		return "UNKNOWN"
+	case AlarmOK:
+		return "OK"
+	case AlarmActive:
+		return "ALARM"
+	}
+	return fmt.Sprintf("UNKNOWN (%d)", int(s))
+}
+
+// UnmarshalJSON implements json.Unmarshaler for AlarmState.
+func (s *AlarmState) UnmarshalJSON(data []byte) error {
+	*s = AlarmState(decodeState(data))
+	return nil
+}
+
+// decodeState decodes a numeric state, or -1 if it is not a number.
+func decodeState(data []byte) int {
+	f, ok := parseNumber(data)
+	if !ok || f < 0 {
+		return -1
+	}
+	return int(math.Round(f))
+}
+
+// Validity records the data fields of a response that were missing, null or
+// malformed. Such fields keep their zero value.
+type Validity struct {
+	invalid []string
+}
+
+// Valid reports whether the data field with the given JSON name, e.g. "p1",
+// was reported correctly.
+func (v Validity) Valid(field string) bool {
+	return !slices.Contains(v.invalid, field)
+}
 
-	// Fall back to unmarshaling as integer
-	var i int
-	if err := json.Unmarshal(data, &i); err != nil {
+// Invalid returns the JSON names of all fields that were not reported
+// correctly.
+func (v Validity) Invalid() []string {
+	return slices.Clone(v.invalid)
+}
+
+// check records the fields of the struct pointed to by data that are not
+// valid in the data object of body.
+func (v *Validity) check(body []byte, data any) error {
+	var envelope struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
 		return err
 	}
-	*si = StringInt(i)
+
+	v.invalid = nil
+	t := reflect.TypeOf(data).Elem()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		raw, ok := envelope.Data[name]
+		switch {
+		case !ok || string(raw) == "null":
+			ok = false
+		case f.Type.Kind() == reflect.String:
+			var s string
+			ok = json.Unmarshal(raw, &s) == nil
+		default:
+			_, ok = parseNumber(raw)
+		}
+		if !ok {
+			v.invalid = append(v.invalid, name)
+		}
+	}
 	return nil
 }
 
+// marshal encodes data as a response body with invalid fields set to null,
+// the inverse of check.
+func (v Validity) marshal(data any) ([]byte, error) {
+	body, err := json.Marshal(data)
+	if err != nil || len(v.invalid) == 0 {
+		return fmt.Appendf(nil, `{"data":%s}`, body), err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	for _, name := range v.invalid {
+		fields[name] = json.RawMessage("null")
+	}
+	return json.Marshal(map[string]any{"data": fields})
+}
+
 type DeviceInfo struct {
 	Data struct {
 		DeviceID string    `json:"deviceId"`
@@ -43,38 +210,107 @@ type DeviceInfo struct {
 		MaxPower StringInt `json:"maxPower"`
 		Firmware string    `json:"devVer"`
 	} `json:"data"`
+	Validity
+}
+
+func (d *DeviceInfo) UnmarshalJSON(body []byte) error {
+	type plain DeviceInfo
+	if err := json.Unmarshal(body, (*plain)(d)); err != nil {
+		return err
+	}
+	return d.check(body, &d.Data)
+}
+
+func (d DeviceInfo) MarshalJSON() ([]byte, error) {
+	return d.marshal(d.Data)
 }
 
 type AlarmInfo struct {
 	Data struct {
-		Og    StringInt `json:"og"`    // Grid fault
-		Isce1 StringInt `json:"isce1"` // PV1 short circuit
-		Isce2 StringInt `json:"isce2"` // PV2 short circuit
-		Oe    StringInt `json:"oe"`    // Output error
+		Og    AlarmState `json:"og"`    // Grid fault
+		Isce1 AlarmState `json:"isce1"` // PV1 short circuit
+		Isce2 AlarmState `json:"isce2"` // PV2 short circuit
+		Oe    AlarmState `json:"oe"`    // Output error
 	} `json:"data"`
+	Validity
+}
+
+func (a *AlarmInfo) UnmarshalJSON(body []byte) error {
+	type plain AlarmInfo
+	// Flags missing from the response stay unknown.
+	a.Data.Og, a.Data.Isce1, a.Data.Isce2, a.Data.Oe = AlarmUnknown, AlarmUnknown, AlarmUnknown, AlarmUnknown
+	if err := json.Unmarshal(body, (*plain)(a)); err != nil {
+		return err
+	}
+	return a.check(body, &a.Data)
+}
+
+func (a AlarmInfo) MarshalJSON() ([]byte, error) {
+	return a.marshal(a.Data)
 }
 
 type OutputData struct {
 	Data struct {
-		P1  int     `json:"p1"`  // Power input 1 in Watts
-		E1  float64 `json:"e1"`  // Energy input 1 today in kWh
-		Te1 float64 `json:"te1"` // Total lifetime energy input 1 in kWh
-		P2  int     `json:"p2"`  // Power input 2 in Watts
-		E2  float64 `json:"e2"`  // Energy input 2 today in kWh
-		Te2 float64 `json:"te2"` // Total lifetime energy input 2 in kWh
+		P1  StringInt   `json:"p1"`  // Power input 1 in Watts
+		E1  StringFloat `json:"e1"`  // Energy input 1 today in kWh
+		Te1 StringFloat `json:"te1"` // Total lifetime energy input 1 in kWh
+		P2  StringInt   `json:"p2"`  // Power input 2 in Watts
+		E2  StringFloat `json:"e2"`  // Energy input 2 today in kWh
+		Te2 StringFloat `json:"te2"` // Total lifetime energy input 2 in kWh
 	} `json:"data"`
+	Validity
+}
+
+func (o *OutputData) UnmarshalJSON(body []byte) error {
+	type plain OutputData
+	if err := json.Unmarshal(body, (*plain)(o)); err != nil {
+		return err
+	}
+	return o.check(body, &o.Data)
+}
+
+func (o OutputData) MarshalJSON() ([]byte, error) {
+	return o.marshal(o.Data)
 }
 
 type PowerStatus struct {
 	Data struct {
-		Status StringInt `json:"status"` // 0 = normal, 1 = off
+		Status PowerState `json:"status"`
 	} `json:"data"`
+	Validity
+}
+
+func (p *PowerStatus) UnmarshalJSON(body []byte) error {
+	type plain PowerStatus
+	// A status missing from the response stays unknown.
+	p.Data.Status = PowerUnknown
+	if err := json.Unmarshal(body, (*plain)(p)); err != nil {
+		return err
+	}
+	return p.check(body, &p.Data)
+}
+
+func (p PowerStatus) MarshalJSON() ([]byte, error) {
+	return p.marshal(p.Data)
 }
 
 type PowerLimit struct {
 	Data struct {
 		MaxPower StringInt `json:"maxPower"`
 	} `json:"data"`
+	Validity
+}
+
+func (l *PowerLimit) UnmarshalJSON(body []byte) error {
+	type plain PowerLimit
+	if err := json.Unmarshal(body, (*plain)(l)); err != nil {
+		return err
+	}
+	return l.check(body, &l.Data)
+}
+
+func (l PowerLimit) MarshalJSON() ([]byte, error) {
+	return l.marshal(l.Data)
 }
 
 type Statistics struct {
@@ -88,4 +324,6 @@ type Statistics struct {
 	TotalEnergyToday    float64
 	TotalEnergyLifetime float64
 	LastUpdate          time.Time
+	// Validity of the output data the statistics were computed from.
+	Validity
 }
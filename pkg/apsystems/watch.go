@@ -0,0 +1,403 @@
+package apsystems
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Endpoint identifies one of the device's read endpoints.
+type Endpoint int
+
+const (
+	EndpointOutput Endpoint = iota
+	EndpointDeviceInfo
+	EndpointAlarm
+	EndpointPowerStatus
+	EndpointMaxPower
+
+	numEndpoints = iota
+)
+
+func (e Endpoint) String() string {
+	switch e {
+	case EndpointOutput:
+		return "output"
+	case EndpointDeviceInfo:
+		return "device info"
+	case EndpointAlarm:
+		return "alarm"
+	case EndpointPowerStatus:
+		return "power status"
+	case EndpointMaxPower:
+		return "max power"
+	default:
+		return "unknown"
+	}
+}
+
+// Endpoints lists all read endpoints in polling order.
+var Endpoints = []Endpoint{EndpointOutput, EndpointDeviceInfo, EndpointAlarm, EndpointPowerStatus, EndpointMaxPower}
+
+// Source is the read side of the device API. It is implemented by *Client and
+// by anything that serves the same readings, e.g. a cache in front of it.
+type Source interface {
+	GetOutputData(ctx context.Context) (*OutputData, error)
+	GetDeviceInfo(ctx context.Context) (*DeviceInfo, error)
+	GetAlarmInfo(ctx context.Context) (*AlarmInfo, error)
+	GetDevicePowerStatus(ctx context.Context) (*PowerStatus, error)
+	GetMaxPower(ctx context.Context) (*PowerLimit, error)
+}
+
+// Snapshot holds the latest successful reading of every endpoint. Fields are
+// nil until the endpoint has been read once.
+type Snapshot struct {
+	Output     *OutputData
+	Statistics *Statistics
+	DeviceInfo *DeviceInfo
+	Alarm      *AlarmInfo
+	Status     *PowerStatus
+	Limit      *PowerLimit
+}
+
+// Update is published whenever an endpoint returns a new value or fails.
+// Snapshot contains the state after the update was applied; on error it is
+// left unchanged.
+type Update struct {
+	Endpoint Endpoint
+	Time     time.Time
+	Err      error
+	Snapshot
+}
+
+// Default polling intervals.
+const (
+	DefaultOutputInterval = 10 * time.Second
+	DefaultStateInterval  = time.Minute
+	DefaultInfoInterval   = time.Hour
+)
+
+const watchTimeout = 10 * time.Second
+
+// WatcherOption configures optional Watcher behaviour.
+type WatcherOption func(*Watcher)
+
+// WithInterval sets how often an endpoint is polled. Zero disables polling
+// of the endpoint; it is then only read on Refresh.
+func WithInterval(ep Endpoint, interval time.Duration) WatcherOption {
+	return func(w *Watcher) {
+		w.intervals[ep] = interval
+	}
+}
+
+// WithDuplicates publishes every reading of the given endpoints (all if none
+// are given), not only changed values. Useful for recorders that want a
+// sample per poll.
+func WithDuplicates(eps ...Endpoint) WatcherOption {
+	if len(eps) == 0 {
+		eps = Endpoints
+	}
+	return func(w *Watcher) {
+		for _, ep := range eps {
+			w.duplicates[ep] = true
+		}
+	}
+}
+
+type subscriber struct {
+	ch chan Update
+	fn func(Update)
+}
+
+// Watcher polls a Source, each endpoint at its own interval, and publishes
+// updates to any number of subscribers. Requests are made one at a time
+// because the EZ1 cannot handle parallel load.
+type Watcher struct {
+	src        Source
+	intervals  [numEndpoints]time.Duration
+	duplicates [numEndpoints]bool
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	daylight   *location
+
+	mu      sync.Mutex
+	subs    map[int]subscriber
+	nextSub int
+	last    [numEndpoints]any
+	latest  Snapshot
+	pending [numEndpoints]bool
+	state   PollState
+	wake    chan struct{}
+	done    bool
+}
+
+func NewWatcher(src Source, opts ...WatcherOption) *Watcher {
+	w := &Watcher{
+		src:        src,
+		minBackoff: DefaultMinBackoff,
+		maxBackoff: DefaultMaxBackoff,
+		subs:       make(map[int]subscriber),
+		wake:       make(chan struct{}, 1),
+	}
+	w.intervals = [numEndpoints]time.Duration{
+		EndpointOutput:      DefaultOutputInterval,
+		EndpointDeviceInfo:  DefaultInfoInterval,
+		EndpointAlarm:       DefaultStateInterval,
+		EndpointPowerStatus: DefaultStateInterval,
+		EndpointMaxPower:    DefaultStateInterval,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Subscribe returns a channel receiving updates and a function to cancel the
+// subscription. Updates are dropped for a subscriber whose buffer is full.
+// The channel is closed on cancel or when Run returns.
+func (w *Watcher) Subscribe(buffer int) (<-chan Update, func()) {
+	ch := make(chan Update, buffer)
+	id := w.add(subscriber{ch: ch})
+	return ch, func() { w.remove(id) }
+}
+
+// OnUpdate registers a callback for updates and returns a function to remove
+// it. Callbacks run on the polling goroutine and must not block.
+func (w *Watcher) OnUpdate(fn func(Update)) func() {
+	id := w.add(subscriber{fn: fn})
+	return func() { w.remove(id) }
+}
+
+func (w *Watcher) add(s subscriber) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	id := w.nextSub
+	w.nextSub++
+	if w.done {
+		if s.ch != nil {
+			close(s.ch)
+		}
+		return id
+	}
+	w.subs[id] = s
+	return id
+}
+
+func (w *Watcher) remove(id int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if s, ok := w.subs[id]; ok {
+		if s.ch != nil {
+			close(s.ch)
+		}
+		delete(w.subs, id)
+	}
+}
+
+// Latest returns the most recent readings.
+func (w *Watcher) Latest() Snapshot {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.latest
+}
+
+// Refresh polls the given endpoints (all if none are given) as soon as
+// possible, e.g. after a control command changed them. The result is
+// published even if the value did not change.
+func (w *Watcher) Refresh(eps ...Endpoint) {
+	if len(eps) == 0 {
+		eps = Endpoints
+	}
+	w.mu.Lock()
+	for _, ep := range eps {
+		w.pending[ep] = true
+	}
+	w.mu.Unlock()
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run polls until ctx is cancelled. All endpoints with an interval are read
+// once immediately. While the device is unreachable only output data is
+// probed, with exponential backoff; once it answers again every endpoint is
+// refreshed right away. With daylight configured, polling pauses overnight
+// except for explicit Refresh calls.
+func (w *Watcher) Run(ctx context.Context) {
+	defer w.close()
+
+	var next [numEndpoints]time.Time
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		now := time.Now()
+		if w.updateSchedule(now) {
+			next = [numEndpoints]time.Time{}
+		}
+		w.mu.Lock()
+		pending := w.pending
+		w.pending = [numEndpoints]bool{}
+		w.mu.Unlock()
+
+		for _, ep := range Endpoints {
+			interval := w.intervals[ep]
+			var due bool
+			switch state := w.State(); state.Mode {
+			case PollNormal:
+				due = interval > 0 && !now.Before(next[ep])
+			case PollBackoff:
+				due = ep == EndpointOutput && !now.Before(state.Until)
+			}
+			if !due && !pending[ep] {
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			if w.poll(ctx, ep, pending[ep]) {
+				// Back online: catch up on everything that was skipped.
+				next = [numEndpoints]time.Time{}
+			}
+			if interval > 0 {
+				next[ep] = time.Now().Add(interval)
+			}
+		}
+
+		var tick <-chan time.Time
+		if wait := w.wait(next); wait >= 0 {
+			timer.Reset(wait)
+			tick = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.wake:
+		case <-tick:
+		}
+		timer.Stop()
+	}
+}
+
+// maxSleep bounds a single overnight wait, so that changes of the wall clock
+// are picked up.
+const maxSleep = 15 * time.Minute
+
+// wait returns the time until the next scheduled poll, or -1 if nothing is
+// scheduled.
+func (w *Watcher) wait(next [numEndpoints]time.Time) time.Duration {
+	switch state := w.State(); state.Mode {
+	case PollBackoff:
+		return max(time.Until(state.Until), 0)
+	case PollSleeping:
+		return min(max(time.Until(state.Until), 0), maxSleep)
+	}
+
+	wait := time.Duration(-1)
+	for _, ep := range Endpoints {
+		if w.intervals[ep] <= 0 {
+			continue
+		}
+		if d := time.Until(next[ep]); wait < 0 || d < wait {
+			wait = max(d, 0)
+		}
+	}
+	return wait
+}
+
+// poll reads one endpoint and publishes the result. It reports whether the
+// device just became reachable again.
+func (w *Watcher) poll(ctx context.Context, ep Endpoint, force bool) bool {
+	ctx, cancel := context.WithTimeout(ctx, watchTimeout)
+	defer cancel()
+
+	value, err := w.read(ctx, ep)
+	now := time.Now()
+
+	w.mu.Lock()
+	recovered := w.observe(now, err)
+	if err != nil {
+		// Forget the last value so the first good reading after an error is
+		// published even if it did not change.
+		w.last[ep] = nil
+		u := Update{Endpoint: ep, Time: now, Err: err, Snapshot: w.latest}
+		w.mu.Unlock()
+		w.publish(u)
+		return false
+	}
+
+	changed := !reflect.DeepEqual(w.last[ep], value)
+	w.last[ep] = value
+	switch v := value.(type) {
+	case *OutputData:
+		w.latest.Output = v
+		w.latest.Statistics = NewStatistics(v, now)
+	case *DeviceInfo:
+		w.latest.DeviceInfo = v
+	case *AlarmInfo:
+		w.latest.Alarm = v
+	case *PowerStatus:
+		w.latest.Status = v
+	case *PowerLimit:
+		w.latest.Limit = v
+	}
+	if !changed && !force && !recovered && !w.duplicates[ep] {
+		w.mu.Unlock()
+		return recovered
+	}
+	u := Update{Endpoint: ep, Time: now, Snapshot: w.latest}
+	w.mu.Unlock()
+	w.publish(u)
+	return recovered
+}
+
+func (w *Watcher) read(ctx context.Context, ep Endpoint) (any, error) {
+	switch ep {
+	case EndpointOutput:
+		return w.src.GetOutputData(ctx)
+	case EndpointDeviceInfo:
+		return w.src.GetDeviceInfo(ctx)
+	case EndpointAlarm:
+		return w.src.GetAlarmInfo(ctx)
+	case EndpointPowerStatus:
+		return w.src.GetDevicePowerStatus(ctx)
+	default:
+		return w.src.GetMaxPower(ctx)
+	}
+}
+
+// publish delivers u to all subscribers. Channels are sent to under the lock
+// so a concurrent cancel cannot close them; callbacks run without it.
+func (w *Watcher) publish(u Update) {
+	w.mu.Lock()
+	var fns []func(Update)
+	for _, s := range w.subs {
+		if s.fn != nil {
+			fns = append(fns, s.fn)
+			continue
+		}
+		select {
+		case s.ch <- u:
+		default:
+		}
+	}
+	w.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(u)
+	}
+}
+
+func (w *Watcher) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.done = true
+	for id, s := range w.subs {
+		if s.ch != nil {
+			close(s.ch)
+		}
+		delete(w.subs, id)
+	}
+}